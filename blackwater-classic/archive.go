@@ -0,0 +1,369 @@
+package blackwater
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A directory of the raw auction dumps we downloaded, kept gzipped
+//
+//	<dir>/<game version>/<region>/<connected realm id>/<auction house id>-<faction>/<hash>.json.gz
+//	<dir>/<game version>/<region>/<connected realm id>/<auction house id>-<faction>/index
+//
+// e.g. era/eu/5284/2-alliance/9f86d081884c7d65.json.gz. Houses without a faction leave it out.
+// The hash is the start of the SHA-256 of the decompressed dump, a dump the house already has is not stored again.
+// The index has a line "<fetched at> <hash> <realm name>" for every time a dump was fetched, so a replay sees every fetch
+// and can store the connected realm and its house before their snapshots.
+type Archive struct {
+	Dir string
+}
+
+// One fetch of a dump of an Archive, the house only tells the region, realm, realm name, house, faction and game version
+// Fetches of the same dump share its Path.
+type ArchivedDump struct {
+	Path      string
+	House     AuctionColumns
+	FetchedAt int64
+	Hash      string
+}
+
+// Hex digits of the SHA-256 that go into the file names
+const archiveHashLength = 16
+
+// Name of the file in every house directory that lists its fetches
+const archiveIndexName = "index"
+
+func NewArchive(dir string) *Archive {
+	return &Archive{Dir: dir}
+}
+
+// Writes the dump of house that was fetched at fetchedAt to the archive and records the fetch in the index of the house
+// Returns the path of the dump and whether it was written, false if the house already had the same dump.
+func (a *Archive) Store(house AuctionColumns, fetchedAt int64, dump *AuctionDump) (string, bool, error) {
+	hash, err := dumpHash(dump)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	dir := filepath.Join(a.Dir, house.GameVersion.String(), Region(house.Region).String(),
+		strconv.Itoa(house.ConnectedRealmID), houseDirName(house))
+	p := filepath.Join(dir, hash+".json.gz")
+
+	stored := false
+
+	if _, err = os.Stat(p); os.IsNotExist(err) {
+		err = writeDump(dir, p, dump)
+		stored = err == nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	// The dump is written first, so that the index never lists a dump that is not there
+	err = appendIndex(dir, fetchedAt, hash, house.Name)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return p, stored, nil
+}
+
+// Writes the dump gzipped to p
+func writeDump(dir string, p string, dump *AuctionDump) error {
+	body := dump.Body
+
+	if !dump.Gzipped {
+		var buffer bytes.Buffer
+		zw := gzip.NewWriter(&buffer)
+
+		if _, err := zw.Write(dump.Body); err != nil {
+			return err
+		}
+
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		body = buffer.Bytes()
+	}
+
+	err := os.MkdirAll(dir, 0777)
+
+	if err != nil {
+		return err
+	}
+
+	// Written next to it first, so that a dump that is cut short never has the name of a complete one
+	tmp, err := os.CreateTemp(dir, ".dump-*")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(body)
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// Adds a fetch to the index of a house, unless it is listed already
+func appendIndex(dir string, fetchedAt int64, hash string, realmName string) error {
+	fetches, err := readIndex(filepath.Join(dir, archiveIndexName))
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, fetch := range fetches {
+		if fetch.FetchedAt == fetchedAt && fetch.Hash == hash {
+			return nil
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, archiveIndexName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+
+	if err != nil {
+		return err
+	}
+
+	// Names are kept on one line
+	realmName = strings.Join(strings.Fields(realmName), " ")
+
+	_, err = fmt.Fprintf(f, "%d %s %s\n", fetchedAt, hash, realmName)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Reads the fetches listed in an index, only FetchedAt, Hash and the Name of the House are set
+// Lines that can't be read, e.g. one that was cut short, are left out.
+func readIndex(p string) ([]ArchivedDump, error) {
+	content, err := os.ReadFile(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fetches := []ArchivedDump{}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+
+		if len(fields) < 2 || len(fields[1]) != archiveHashLength {
+			continue
+		}
+
+		fetchedAt, err := strconv.ParseInt(fields[0], 10, 64)
+
+		if err != nil {
+			continue
+		}
+
+		fetch := ArchivedDump{FetchedAt: fetchedAt, Hash: fields[1]}
+
+		if len(fields) == 3 {
+			fetch.House.Name = fields[2]
+		}
+
+		fetches = append(fetches, fetch)
+	}
+
+	return fetches, nil
+}
+
+// Lists every fetch in the indexes of the archive in the order they were fetched
+// Directories that do not follow the layout of the archive are left out.
+func (a *Archive) Dumps() ([]ArchivedDump, error) {
+	dumps := []ArchivedDump{}
+
+	games, err := os.ReadDir(a.Dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, game := range games {
+		gameVersion, err := ParseGameVersion(game.Name())
+
+		if err != nil || !game.IsDir() {
+			continue
+		}
+
+		regions, err := os.ReadDir(filepath.Join(a.Dir, game.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, regionDir := range regions {
+			region, err := ParseRegion(regionDir.Name())
+
+			if err != nil || !regionDir.IsDir() {
+				continue
+			}
+
+			realms, err := os.ReadDir(filepath.Join(a.Dir, game.Name(), regionDir.Name()))
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, realm := range realms {
+				connectedRealmID, err := strconv.Atoi(realm.Name())
+
+				if err != nil || !realm.IsDir() {
+					continue
+				}
+
+				houses, err := os.ReadDir(filepath.Join(a.Dir, game.Name(), regionDir.Name(), realm.Name()))
+
+				if err != nil {
+					return nil, err
+				}
+
+				for _, houseDir := range houses {
+					house, ok := parseHouseDirName(houseDir.Name())
+
+					if !ok || !houseDir.IsDir() {
+						continue
+					}
+
+					house.ConnectedRealmID = connectedRealmID
+					house.Region = int(region)
+					house.GameVersion = gameVersion
+
+					dir := filepath.Join(a.Dir, game.Name(), regionDir.Name(), realm.Name(), houseDir.Name())
+					fetches, err := readIndex(filepath.Join(dir, archiveIndexName))
+
+					if os.IsNotExist(err) {
+						continue
+					}
+
+					if err != nil {
+						return nil, err
+					}
+
+					for _, fetch := range fetches {
+						house.Name = fetch.House.Name
+						fetch.Path = filepath.Join(dir, fetch.Hash+".json.gz")
+						fetch.House = house
+						dumps = append(dumps, fetch)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(dumps, func(i, j int) bool {
+		return dumps[i].FetchedAt < dumps[j].FetchedAt
+	})
+
+	return dumps, nil
+}
+
+// Reads the dump back, it is checked against the hash in its name
+func (d ArchivedDump) Load() (*AuctionDump, error) {
+	body, err := os.ReadFile(d.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &AuctionDump{URL: d.Path, Body: body, Gzipped: true}
+
+	hash, err := dumpHash(dump)
+
+	if err != nil {
+		return nil, &DecodeError{URL: d.Path, Err: err}
+	}
+
+	if hash != d.Hash {
+		return nil, &DecodeError{URL: d.Path, Err: fmt.Errorf("the dump has changed, its hash is %s", hash)}
+	}
+
+	return dump, nil
+}
+
+// The start of the SHA-256 of the decompressed dump
+func dumpHash(dump *AuctionDump) (string, error) {
+	r, err := dump.Reader()
+
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, r)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:archiveHashLength], nil
+}
+
+// e.g. 2-alliance, or 9 for a house without a faction
+func houseDirName(house AuctionColumns) string {
+	name := strconv.Itoa(house.AuctionHouseID)
+
+	if house.FactionID.Valid && house.FactionID.Int64 >= 0 && int(house.FactionID.Int64) < len(FactionNames) {
+		name += "-" + FactionNames[house.FactionID.Int64]
+	}
+
+	return name
+}
+
+func parseHouseDirName(name string) (AuctionColumns, bool) {
+	var house AuctionColumns
+
+	id, faction, hasFaction := strings.Cut(name, "-")
+
+	auctionHouseID, err := strconv.Atoi(id)
+
+	if err != nil {
+		return house, false
+	}
+
+	house.AuctionHouseID = auctionHouseID
+
+	if !hasFaction {
+		return house, true
+	}
+
+	for i, factionName := range FactionNames {
+		if factionName == faction {
+			house.FactionID = sql.NullInt64{Int64: int64(i), Valid: true}
+			return house, true
+		}
+	}
+
+	return house, false
+}
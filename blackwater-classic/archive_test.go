@@ -0,0 +1,94 @@
+package blackwater
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	archive := NewArchive(t.TempDir())
+	alliance := AuctionColumns{ConnectedRealmID: 5284, Region: int(EU), Name: "Nethergarde Keep", AuctionHouseID: 2,
+		FactionID: sql.NullInt64{Int64: Alliance, Valid: true}, GameVersion: Era}
+	seasonal := AuctionColumns{ConnectedRealmID: 5284, Region: int(EU), Name: "Nethergarde Keep", AuctionHouseID: 9, GameVersion: Era}
+
+	// The same ID in another region is another connected realm
+	american := AuctionColumns{ConnectedRealmID: 5284, Region: int(US), Name: "Whitemane", AuctionHouseID: 2, GameVersion: Era}
+
+	dump := gzipTestDump(t, testAuctionDump)
+
+	p, stored, err := archive.Store(alliance, 1700000000, dump)
+	if err != nil || !stored {
+		t.Fatalf("expected the dump to be stored, got %v, %v", stored, err)
+	}
+
+	if filepath.Dir(p) != filepath.Join(archive.Dir, "era", "eu", "5284", "2-alliance") || !strings.HasSuffix(p, ".json.gz") {
+		t.Errorf("unexpected path %s", p)
+	}
+
+	// The same dump an hour later is already in the archive, only the fetch is recorded
+	again, stored, err := archive.Store(alliance, 1700003600, dump)
+	if err != nil || stored || again != p {
+		t.Errorf("expected %s to be kept, got %s, %v, %v", p, again, stored, err)
+	}
+
+	// A fetch is only recorded once
+	if _, _, err = archive.Store(alliance, 1700003600, dump); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dumps that were not sent gzipped are compressed
+	plain := &AuctionDump{URL: dump.URL, Body: []byte(`{"auctions": []}`)}
+
+	if _, stored, err = archive.Store(seasonal, 1700007200, plain); err != nil || !stored {
+		t.Fatalf("expected the dump to be stored, got %v, %v", stored, err)
+	}
+
+	if _, stored, err = archive.Store(american, 1700010800, dump); err != nil || !stored {
+		t.Fatalf("expected the dump of the other region to be stored, got %v, %v", stored, err)
+	}
+
+	dumps, err := archive.Dumps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dumps) != 4 || dumps[0].Path != p || dumps[1].Path != p || dumps[0].House != alliance || dumps[1].FetchedAt != 1700003600 ||
+		dumps[2].House != seasonal || dumps[2].FetchedAt != 1700007200 || dumps[3].House != american || dumps[3].Path == p {
+		t.Fatalf("unexpected dumps: %+v", dumps)
+	}
+
+	files, err := filepath.Glob(filepath.Join(archive.Dir, "era", "eu", "5284", "2-alliance", "*.json.gz"))
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected the dump to be written once, got %v, %v", files, err)
+	}
+
+	for _, archived := range dumps {
+		loaded, err := archived.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := loaded.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = DecodeAuctions(r, 10, func([]AuctionListingJson) error { return nil }); err != nil {
+			t.Errorf("%s: %v", archived.Path, err)
+		}
+	}
+
+	// A dump that was changed on disk is not loaded
+	if err = os.WriteFile(p, gzipTestDump(t, `{"auctions": []}`).Body, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var decodeError *DecodeError
+	if _, err = dumps[0].Load(); !errors.As(err, &decodeError) {
+		t.Errorf("expected a DecodeError for the changed dump, got %v", err)
+	}
+}
@@ -2,90 +2,519 @@ package blackwater
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
-func InsertAuctions(db *sql.DB, auctionJson AuctionJson, importTime int64, connectedRealmID int, faction_id int) error {
+var auctionInsertColumns = []string{
+	"auction_id", "bid", "buyout", "unit_price", "quantity", "time_left",
+	"timestamp",
+	"item_id", "item_rand", "item_seed", "item_modifiers", "item_bonus_lists",
+	"connected_realm_id", "auction_house_id", "faction_id", "game_version",
+	"snapshot_id", "last_snapshot_id",
+}
+
+// An auction that is seen again with a different time left is a new row
+var auctionKeyColumns = []string{"auction_id", "connected_realm_id", "time_left"}
 
-	// Do something with the auction data
-	tx, err := db.Begin()
+// A row that is seen again keeps the snapshot it was first seen in
+var auctionUpdateColumns = []string{
+	"bid", "buyout", "unit_price", "quantity",
+	"timestamp",
+	"item_id", "item_rand", "item_seed", "item_modifiers", "item_bonus_lists",
+	"auction_house_id", "faction_id", "game_version",
+	"last_snapshot_id",
+}
+
+// Auctions are decoded and handed to the database in batches of this size
+// SQLite and MySQL also commit after every batch, to PendingAuctions until the whole dump is read.
+const auctionBatchSize = 10000
+
+// Stores the auctions of one auction house, house tells which realm, house and game version they belong to
+// snapshotID is the snapshot the auctions were fetched in, see BeginSnapshot.
+// With delta storage they are only kept in PendingAuctions until TrackLifecycles has recorded them.
+func InsertAuctions(db *Database, auctionJson AuctionJson, importTime int64, house AuctionColumns, snapshotID int64) error {
+	w, err := newAuctionWriter(db, importTime, house, snapshotID)
 
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO Auctions(
-		auction_id, buyout, quantity, time_left,
-		timestamp, 
-		item_id, connected_realm_id, faction_id) VALUES(
-		?, ?, ?, ?,
-		?,
-		?, ?, ?
-		)`)
+	err = w.Write(auctionJson.Auctions)
 
 	if err != nil {
+		w.Rollback()
 		return err
 	}
 
-	defer stmt.Close()
+	return w.Commit()
+}
+
+// Same as InsertAuctions, but decodes the dump as it is read
+// Only one batch of auctions is held in memory. If the dump turns out to be broken halfway,
+// none of it is stored, the number of auctions read so far is returned with the error.
+func InsertAuctionsFrom(db *Database, dump *AuctionDump, importTime int64, house AuctionColumns, snapshotID int64) (int, error) {
+	r, err := dump.Reader()
 
-	counter := 0
-	commitSize := 10000
+	if err != nil {
+		return 0, &DecodeError{URL: dump.URL, Err: err}
+	}
 
-	// Every batch should have the same import time
-	// this might make it easier for SQL to sort
-	for _, auction := range auctionJson.Auctions {
+	w, err := newAuctionWriter(db, importTime, house, snapshotID)
 
-		_, err = stmt.Exec(auction.ID, auction.Buyout, auction.Quantity, auction.TimeLeft,
-			importTime,
-			auction.Item.ID, connectedRealmID, faction_id)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := DecodeAuctions(r, auctionBatchSize, w.Write)
+
+	if err != nil {
+		w.Rollback()
+
+		var decodeError *DecodeError
+		if errors.As(err, &decodeError) {
+			decodeError.URL = dump.URL
+		}
+
+		return count, err
+	}
+
+	return count, w.Commit()
+}
+
+// Decodes the auctions of a dump as they are read from r and hands them to handle in batches of batchSize
+// The batch is reused, handle must not keep it. The other fields of the dump are skipped.
+// Returns the number of auctions that were handled, errors of the dump itself are DecodeErrors.
+func DecodeAuctions(r io.Reader, batchSize int, handle func([]AuctionListingJson) error) (int, error) {
+	dec := json.NewDecoder(r)
+	count := 0
+
+	err := expectDelim(dec, '{')
+
+	if err != nil {
+		return 0, &DecodeError{Err: err}
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
 
 		if err != nil {
-			tx.Rollback()
-			return nil
+			return count, &DecodeError{Err: err}
 		}
 
-		counter++
+		if key, _ := token.(string); key != "auctions" {
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+
+			if err != nil {
+				return count, &DecodeError{Err: err}
+			}
+
+			continue
+		}
 
-		if counter >= commitSize {
+		err = expectDelim(dec, '[')
+
+		if err != nil {
+			return count, &DecodeError{Err: err}
+		}
+
+		batch := make([]AuctionListingJson, 0, batchSize)
+
+		for dec.More() {
+			batch = append(batch, AuctionListingJson{})
+			err = dec.Decode(&batch[len(batch)-1])
 
-			err = tx.Commit()
 			if err != nil {
-				log.Println("Could not commit")
-				return err
+				return count, &DecodeError{Err: fmt.Errorf("auction %d: %w", count+len(batch), err)}
 			}
-			tx, err = db.Begin()
+
+			if len(batch) >= batchSize {
+				err = handle(batch)
+
+				if err != nil {
+					return count, err
+				}
+
+				count += len(batch)
+				batch = batch[:0]
+			}
+		}
+
+		if len(batch) > 0 {
+			err = handle(batch)
+
+			if err != nil {
+				return count, err
+			}
+
+			count += len(batch)
+		}
+
+		err = expectDelim(dec, ']')
+
+		if err != nil {
+			return count, &DecodeError{Err: err}
+		}
+	}
+
+	err = expectDelim(dec, '}')
+
+	if err != nil {
+		return count, &DecodeError{Err: err}
+	}
+
+	return count, nil
+}
+
+// Price of a single item, rounded to the nearest copper
+// NULL for bid-only auctions, since what they sell for is not known yet.
+func (auction *AuctionListingJson) PricePerUnit() sql.NullInt64 {
+	if auction.UnitPrice > 0 {
+		return sql.NullInt64{Int64: int64(auction.UnitPrice), Valid: true}
+	}
+
+	if auction.Buyout > 0 && auction.Quantity > 0 {
+		quantity := int64(auction.Quantity)
+		return sql.NullInt64{Int64: (int64(auction.Buyout) + quantity/2) / quantity, Valid: true}
+	}
+
+	return sql.NullInt64{}
+}
+
+// The values of auctionInsertColumns that come from the auction itself, in order
+// Missing bids, buyouts, suffixes and seeds are NULL, modifiers and bonus lists are stored as JSON arrays.
+func auctionValues(auction *AuctionListingJson) (bid sql.NullInt64, buyout sql.NullInt64, unitPrice sql.NullInt64, rand sql.NullInt64, seed sql.NullInt64, modifiers sql.NullString, bonusLists sql.NullString) {
+	bid = nullIfZero(int64(auction.Bid))
+	buyout = nullIfZero(int64(auction.Buyout))
+	unitPrice = auction.PricePerUnit()
+	rand = nullIfZero(int64(auction.Item.Rand))
+	seed = nullIfZero(auction.Item.Seed)
+
+	if len(auction.Item.Modifiers) > 0 {
+		encoded, _ := json.Marshal(auction.Item.Modifiers)
+		modifiers = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	if len(auction.Item.BonusLists) > 0 {
+		encoded, _ := json.Marshal(auction.Item.BonusLists)
+		bonusLists = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	return
+}
+
+func nullIfZero(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("expected %s in the auction dump, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// Writes the auctions of one house, batch by batch
+type auctionWriter interface {
+	Write(auctions []AuctionListingJson) error
+	Commit() error
+	Rollback()
+}
+
+func newAuctionWriter(db *Database, importTime int64, house AuctionColumns, snapshotID int64) (auctionWriter, error) {
+	if db.Dialect() == Postgres {
+		return newCopyAuctionWriter(db, importTime, house, snapshotID)
+	}
+
+	w := &upsertAuctionWriter{
+		db:         db,
+		query:      db.Dialect().UpsertUpdating("PendingAuctions", auctionInsertColumns, auctionUpdateColumns, auctionKeyColumns),
+		move:       db.AuctionStorage == FullStorage,
+		importTime: importTime,
+		house:      house,
+		snapshotID: snapshotID,
+	}
+
+	err := w.begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SQLite and MySQL: inserts the auctions into PendingAuctions with a prepared upsert and commits every auctionBatchSize rows
+// Once the dump is read, full storage moves them into Auctions with a single upsert,
+// so a dump that breaks halfway never mixes its auctions with those of complete snapshots.
+type upsertAuctionWriter struct {
+	db         *Database
+	query      string
+	move       bool // Whether Commit moves the auctions into Auctions, delta storage leaves them to TrackLifecycles
+	tx         *sql.Tx
+	stmt       *sql.Stmt
+	pending    int
+	importTime int64
+	house      AuctionColumns
+	snapshotID int64
+}
+
+func (w *upsertAuctionWriter) begin() error {
+	tx, err := w.db.Handle.Begin()
+
+	if err != nil {
+		log.Println("Could not begin")
+		return err
+	}
+
+	stmt, err := tx.Prepare(w.query)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	w.tx = tx
+	w.stmt = stmt
+	w.pending = 0
+
+	return nil
+}
+
+func (w *upsertAuctionWriter) Write(auctions []AuctionListingJson) error {
+	house := w.house
+
+	// Every batch should have the same import time
+	// this might make it easier for SQL to sort
+	for i := range auctions {
+		auction := &auctions[i]
+		bid, buyout, unitPrice, rand, seed, modifiers, bonusLists := auctionValues(auction)
+
+		_, err := w.stmt.Exec(auction.ID, bid, buyout, unitPrice, auction.Quantity, auction.TimeLeft,
+			w.importTime,
+			auction.Item.ID, rand, seed, modifiers, bonusLists,
+			house.ConnectedRealmID, house.AuctionHouseID, house.FactionID, house.GameVersion,
+			w.snapshotID, w.snapshotID)
+
+		if err != nil {
+			return err
+		}
+
+		w.pending++
+
+		if w.pending >= auctionBatchSize {
+			err = w.commitBatch()
+
 			if err != nil {
-				log.Println("Could not begin")
 				return err
 			}
 
-			stmt, err = tx.Prepare(`INSERT OR REPLACE INTO Auctions(
-				auction_id, buyout, quantity, time_left,
-				timestamp, 
-				item_id, connected_realm_id, faction_id) VALUES(
-				?, ?, ?, ?,
-				?,
-				?, ?, ?
-				)`)
+			err = w.begin()
 
 			if err != nil {
 				return err
 			}
+		}
+	}
+
+	return nil
+}
+
+func (w *upsertAuctionWriter) Commit() error {
+	err := w.commitBatch()
 
-			counter = 0
+	if err != nil || !w.move {
+		return err
+	}
+
+	return w.moveToAuctions()
+}
+
+func (w *upsertAuctionWriter) commitBatch() error {
+	w.stmt.Close()
+
+	err := w.tx.Commit()
+	w.tx = nil
+
+	if err != nil {
+		log.Println("Could not commit")
+		return err
+	}
+
+	return nil
+}
+
+// Upserts the auctions of the snapshot from PendingAuctions into Auctions and removes what the house left there
+// Every pending row belongs to the snapshot, so new rows are first seen in it as well.
+// The select is wrapped and filtered, SQLite would otherwise read ON CONFLICT as part of a join.
+func (w *upsertAuctionWriter) moveToAuctions() error {
+	dialect := w.db.Dialect()
+
+	selected := []string{}
+	for _, column := range auctionInsertColumns {
+		if column == "snapshot_id" {
+			column = "last_snapshot_id AS snapshot_id"
 		}
 
+		selected = append(selected, column)
 	}
 
-	err = tx.Commit()
+	tx, err := w.db.Handle.Begin()
 
 	if err != nil {
-		return nil
+		return err
 	}
 
-	log.Println("Finished importing auctions to DB.")
+	defer tx.Rollback()
+
+	_, err = tx.Exec(dialect.Rebind(fmt.Sprintf(`INSERT INTO Auctions (%s)
+		SELECT * FROM (
+			SELECT %s FROM PendingAuctions WHERE last_snapshot_id = ?
+		) AS pending WHERE TRUE`, strings.Join(auctionInsertColumns, ", "), strings.Join(selected, ", ")))+
+		dialect.onConflict(auctionUpdateColumns, auctionKeyColumns), w.snapshotID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(dialect.Rebind(`DELETE FROM PendingAuctions WHERE connected_realm_id = ? AND auction_house_id = ?`),
+		w.house.ConnectedRealmID, w.house.AuctionHouseID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Rolls back the batch and removes the batches of the snapshot that were already committed
+func (w *upsertAuctionWriter) Rollback() {
+	if w.tx != nil {
+		w.stmt.Close()
+		w.tx.Rollback()
+		w.tx = nil
+	}
+
+	_, err := w.db.Handle.Exec(w.db.Dialect().Rebind(`DELETE FROM PendingAuctions WHERE last_snapshot_id = ?`), w.snapshotID)
+
+	if err != nil {
+		log.Printf("Could not remove the pending auctions of snapshot %d: %q\n", w.snapshotID, err)
+	}
+}
+
+// Postgres: streams the auctions into a temporary table with COPY FROM STDIN,
+// then moves them into Auctions, or PendingAuctions, with a single upsert when it commits
+type copyAuctionWriter struct {
+	db         *Database
+	tx         *sql.Tx
+	stmt       *sql.Stmt
+	timestamp  interface{}
+	house      AuctionColumns
+	snapshotID int64
+}
+
+func newCopyAuctionWriter(db *Database, importTime int64, house AuctionColumns, snapshotID int64) (*copyAuctionWriter, error) {
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := strings.Join(auctionInsertColumns, ", ")
+
+	_, err = tx.Exec(fmt.Sprintf(`CREATE TEMPORARY TABLE auctions_import ON COMMIT DROP AS
+		SELECT %s FROM %s WITH NO DATA`, columns, db.auctionTable()))
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("auctions_import", auctionInsertColumns...))
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &copyAuctionWriter{
+		db:         db,
+		tx:         tx,
+		stmt:       stmt,
+		timestamp:  db.Dialect().timestamp(importTime),
+		house:      house,
+		snapshotID: snapshotID,
+	}, nil
+}
+
+func (w *copyAuctionWriter) Write(auctions []AuctionListingJson) error {
+	house := w.house
+
+	for i := range auctions {
+		auction := &auctions[i]
+		bid, buyout, unitPrice, rand, seed, modifiers, bonusLists := auctionValues(auction)
+
+		_, err := w.stmt.Exec(auction.ID, bid, buyout, unitPrice, auction.Quantity, auction.TimeLeft,
+			w.timestamp,
+			auction.Item.ID, rand, seed, modifiers, bonusLists,
+			house.ConnectedRealmID, house.AuctionHouseID, house.FactionID, house.GameVersion,
+			w.snapshotID, w.snapshotID)
+
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
+}
+
+func (w *copyAuctionWriter) Commit() error {
+	defer w.tx.Rollback()
+
+	// Flushes the rows that are still buffered
+	_, err := w.stmt.Exec()
+
+	if err != nil {
+		w.stmt.Close()
+		return err
+	}
+
+	err = w.stmt.Close()
+
+	if err != nil {
+		return err
+	}
+
+	// An upsert may not touch the same row twice, so duplicates within the snapshot are dropped
+	columns := strings.Join(auctionInsertColumns, ", ")
+	keys := strings.Join(auctionKeyColumns, ", ")
+
+	_, err = w.tx.Exec(fmt.Sprintf(`INSERT INTO %s (%s)
+		SELECT DISTINCT ON (%s) %s FROM auctions_import`, w.db.auctionTable(), columns, keys, columns) +
+		w.db.Dialect().onConflict(auctionUpdateColumns, auctionKeyColumns))
+
+	if err != nil {
+		return err
+	}
+
+	return w.tx.Commit()
+}
 
+func (w *copyAuctionWriter) Rollback() {
+	w.stmt.Close()
+	w.tx.Rollback()
 }
@@ -0,0 +1,421 @@
+package blackwater
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const testAuctionDump = `{
+	"_links": {"self": {"href": "https://eu.api.blizzard.com/data/wow/connected-realm/5284/auctions/2"}},
+	"connected_realm": {"href": "https://eu.api.blizzard.com/data/wow/connected-realm/5284"},
+	"auctions": [
+		{"id": 1, "item": {"id": 13444}, "bid": 1000, "buyout": 1500, "quantity": 2, "time_left": "MEDIUM"},
+		{"id": 2, "item": {"id": 10118, "rand": 1017, "seed": 1430265344, "modifiers": [{"type": 9, "value": 60}]}, "buyout": 3000, "quantity": 3, "time_left": "LONG"},
+		{"id": 3, "item": {"id": 13452}, "buyout": 0, "quantity": 1, "time_left": "SHORT"}
+	],
+	"id": 2,
+	"name": "Alliance Auction House"
+}`
+
+func TestDecodeAuctions(t *testing.T) {
+	batches := [][]AuctionListingJson{}
+
+	count, err := DecodeAuctions(strings.NewReader(testAuctionDump), 2, func(batch []AuctionListingJson) error {
+		batches = append(batches, append([]AuctionListingJson(nil), batch...))
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 || len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected 3 auctions in batches of 2, got %d in %v", count, batches)
+	}
+
+	auction := batches[0][1]
+	if auction.ID != 2 || auction.Item.ID != 10118 || auction.Buyout != 3000 || auction.Quantity != 3 || auction.TimeLeft != "LONG" {
+		t.Errorf("unexpected auction: %+v", auction)
+	}
+
+	if auction.Item.Rand != 1017 || auction.Item.Seed != 1430265344 || len(auction.Item.Modifiers) != 1 || auction.Item.Modifiers[0].Value != 60 {
+		t.Errorf("unexpected item: %+v", auction.Item)
+	}
+
+	// Same result as decoding the whole dump
+	var auctionJson AuctionJson
+	if err = json.Unmarshal([]byte(testAuctionDump), &auctionJson); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(auctionJson.Auctions[2], batches[1][0]) {
+		t.Errorf("streamed %+v, unmarshaled %+v", batches[1][0], auctionJson.Auctions[2])
+	}
+
+	for _, broken := range []string{
+		``,
+		`[]`,
+		`{"auctions": {}}`,
+		testAuctionDump[:strings.Index(testAuctionDump, `{"id": 3`)],
+	} {
+		_, err = DecodeAuctions(strings.NewReader(broken), 2, func([]AuctionListingJson) error { return nil })
+
+		var decodeError *DecodeError
+		if !errors.As(err, &decodeError) {
+			t.Errorf("%q: expected a DecodeError, got %v", broken, err)
+		}
+	}
+
+	// Errors of handle are returned as they are
+	handleErr := errors.New("database is gone")
+	_, err = DecodeAuctions(strings.NewReader(testAuctionDump), 2, func([]AuctionListingJson) error { return handleErr })
+
+	if err != handleErr {
+		t.Errorf("expected the error of handle, got %v", err)
+	}
+}
+
+func TestAuctionValues(t *testing.T) {
+	tests := []struct {
+		auction   string
+		bid       sql.NullInt64
+		buyout    sql.NullInt64
+		unitPrice sql.NullInt64
+		rand      sql.NullInt64
+		bonus     sql.NullString
+	}{
+		// The buyout is for the whole stack
+		{`{"id": 1, "item": {"id": 5634}, "bid": 3000, "buyout": 4500, "quantity": 4}`,
+			sql.NullInt64{Int64: 3000, Valid: true}, sql.NullInt64{Int64: 4500, Valid: true}, sql.NullInt64{Int64: 1125, Valid: true}, sql.NullInt64{}, sql.NullString{}},
+		// Rounded to the nearest copper
+		{`{"id": 2, "item": {"id": 5634}, "buyout": 1000, "quantity": 3}`,
+			sql.NullInt64{}, sql.NullInt64{Int64: 1000, Valid: true}, sql.NullInt64{Int64: 333, Valid: true}, sql.NullInt64{}, sql.NullString{}},
+		// Bid-only auctions have no price yet
+		{`{"id": 3, "item": {"id": 10118, "rand": 1017}, "bid": 5000, "quantity": 1}`,
+			sql.NullInt64{Int64: 5000, Valid: true}, sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{Int64: 1017, Valid: true}, sql.NullString{}},
+		// Retail stackables are priced per unit
+		{`{"id": 4, "item": {"id": 18832, "bonus_lists": [6654, 1701]}, "unit_price": 250, "quantity": 20}`,
+			sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{Int64: 250, Valid: true}, sql.NullInt64{}, sql.NullString{String: "[6654,1701]", Valid: true}},
+	}
+
+	for _, test := range tests {
+		var auction AuctionListingJson
+		if err := json.Unmarshal([]byte(test.auction), &auction); err != nil {
+			t.Fatal(err)
+		}
+
+		bid, buyout, unitPrice, rand, _, _, bonus := auctionValues(&auction)
+
+		if bid != test.bid || buyout != test.buyout || unitPrice != test.unitPrice || rand != test.rand || bonus != test.bonus {
+			t.Errorf("%s: got bid %v, buyout %v, unit price %v, rand %v, bonus lists %v", test.auction, bid, buyout, unitPrice, rand, bonus)
+		}
+	}
+}
+
+func TestInsertAuctionsFrom(t *testing.T) {
+	database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+
+	count, err := InsertAuctionsFrom(database, gzipTestDump(t, testAuctionDump), 1700000000, house, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	err = database.Handle.QueryRow(`SELECT COUNT(*) FROM Auctions WHERE snapshot_id = 7 AND connected_realm_id = 5284`).Scan(&stored)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 || stored != 3 {
+		t.Errorf("expected 3 auctions, decoded %d and stored %d", count, stored)
+	}
+
+	// A dump that is cut off is a decode error that names the dump
+	broken := gzipTestDump(t, testAuctionDump[:len(testAuctionDump)/2])
+	_, err = InsertAuctionsFrom(database, broken, 1700003600, house, 8)
+
+	var decodeError *DecodeError
+	if !errors.As(err, &decodeError) || decodeError.URL != broken.URL {
+		t.Errorf("expected a DecodeError for %s, got %v", broken.URL, err)
+	}
+
+	// A dump that breaks after a committed batch leaves nothing behind either
+	listings := []string{}
+	for id := 1; id <= auctionBatchSize+10; id++ {
+		listings = append(listings, fmt.Sprintf(`{"id": %d, "item": {"id": 13444}, "buyout": 1500, "quantity": 1, "time_left": "MEDIUM"}`, id))
+	}
+
+	large := `{"auctions": [` + strings.Join(listings, ",\n") + `]}`
+	count, err = InsertAuctionsFrom(database, gzipTestDump(t, large[:len(large)-100]), 1700007200, house, 9)
+
+	if !errors.As(err, &decodeError) || count != auctionBatchSize {
+		t.Errorf("expected a DecodeError after %d auctions, got %d, %v", auctionBatchSize, count, err)
+	}
+
+	for _, table := range []string{"Auctions", "PendingAuctions"} {
+		err = database.Handle.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE last_snapshot_id <> 7`).Scan(&stored)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stored != 0 {
+			t.Errorf("expected no auctions of the broken dumps in %s, got %d", table, stored)
+		}
+	}
+}
+
+func TestAuctionSnapshots(t *testing.T) {
+	database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+
+	// 1 and 2 are listed in the first two snapshots, only 1 is left for the third with less time
+	importTestDumps(t, database, house, 1700000000, []string{
+		`{"auctions": [
+			{"id": 1, "item": {"id": 10118, "rand": 1017}, "buyout": 5000, "quantity": 1, "time_left": "LONG"},
+			{"id": 2, "item": {"id": 10118, "rand": 1017}, "bid": 3000, "quantity": 1, "time_left": "LONG"}
+		]}`,
+		`{"auctions": [
+			{"id": 1, "item": {"id": 10118, "rand": 1017}, "buyout": 5000, "quantity": 1, "time_left": "LONG"},
+			{"id": 2, "item": {"id": 10118, "rand": 1017}, "bid": 3000, "quantity": 1, "time_left": "LONG"}
+		]}`,
+		`{"auctions": [
+			{"id": 1, "item": {"id": 10118, "rand": 1017}, "buyout": 5000, "quantity": 1, "time_left": "MEDIUM"}
+		]}`,
+	})
+
+	var first, last int64
+	err := database.Handle.QueryRow(`SELECT snapshot_id, last_snapshot_id FROM Auctions WHERE auction_id = 1 AND time_left = 'LONG'`).Scan(&first, &last)
+
+	if err != nil || first != 1 || last != 2 {
+		t.Errorf("expected the row to be seen from snapshot 1 to 2, got %d to %d, %v", first, last, err)
+	}
+
+	query, err := os.ReadFile("../sql-statements/suffix_prices.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := database.Handle.Query(string(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	counted := [][2]int{}
+	for rows.Next() {
+		var at string
+		var rand, minUnitPrice, buyouts, bidOnly int
+
+		if err = rows.Scan(&at, &rand, &minUnitPrice, &buyouts, &bidOnly); err != nil {
+			t.Fatal(err)
+		}
+
+		counted = append(counted, [2]int{buyouts, bidOnly})
+	}
+
+	// Every snapshot counts the auctions it listed, not only the last snapshot they were seen in
+	expected := [][2]int{{1, 1}, {1, 1}, {1, 0}}
+	if !reflect.DeepEqual(counted, expected) {
+		t.Errorf("expected buyouts and bid-only auctions %v, got %v", expected, counted)
+	}
+}
+
+func gzipTestDump(t testing.TB, dump string) *AuctionDump {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	zw := gzip.NewWriter(&buffer)
+
+	if _, err := zw.Write([]byte(dump)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &AuctionDump{URL: "https://eu.api.blizzard.com/data/wow/connected-realm/5284/auctions/2", Body: buffer.Bytes(), Gzipped: true}
+}
+
+// Builds a dump of n auctions from the auctions of a recorded fixture
+func largeTestDump(b *testing.B, n int) string {
+	b.Helper()
+
+	fixture, err := os.ReadFile("blackwatertest/fixtures/dynamic-classic1x-eu/data/wow/connected-realm/5284/auctions/7.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var recorded struct {
+		Auctions []json.RawMessage `json:"auctions"`
+	}
+
+	if err = json.Unmarshal(fixture, &recorded); err != nil {
+		b.Fatal(err)
+	}
+
+	var dump strings.Builder
+	dump.WriteString(`{"_links": {"self": {"href": "https://eu.api.blizzard.com/"}}, "auctions": [`)
+
+	for i := 0; i < n; i++ {
+		auction := map[string]interface{}{}
+		if err = json.Unmarshal(recorded.Auctions[i%len(recorded.Auctions)], &auction); err != nil {
+			b.Fatal(err)
+		}
+
+		auction["id"] = i + 1
+
+		encoded, err := json.Marshal(auction)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if i > 0 {
+			dump.WriteString(",")
+		}
+
+		dump.Write(encoded)
+	}
+
+	dump.WriteString(`], "id": 7, "name": "Blackwater Auction House"}`)
+
+	return dump.String()
+}
+
+// Compares decoding a dump of 200,000 auctions in one piece, as fetchJson does, with streaming it
+// Reports the throughput of the decompressed JSON and the peak heap and RSS of a decode.
+//
+//	go test ./blackwater-classic -run '^$' -bench DecodeAuctions -benchtime 5x
+func BenchmarkDecodeAuctions(b *testing.B) {
+	raw := largeTestDump(b, 200000)
+	dump := gzipTestDump(b, raw)
+
+	b.Run("unmarshal", func(b *testing.B) {
+		b.SetBytes(int64(len(raw)))
+		measurePeakMemory(b, func() {
+			body, err := fasthttp.AppendGunzipBytes(nil, dump.Body)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var auctionJson AuctionJson
+			if err = json.Unmarshal(body, &auctionJson); err != nil {
+				b.Fatal(err)
+			}
+
+			if len(auctionJson.Auctions) != 200000 {
+				b.Fatalf("decoded %d auctions", len(auctionJson.Auctions))
+			}
+		})
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.SetBytes(int64(len(raw)))
+		measurePeakMemory(b, func() {
+			r, err := dump.Reader()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			count, err := DecodeAuctions(r, auctionBatchSize, func([]AuctionListingJson) error { return nil })
+			if err != nil || count != 200000 {
+				b.Fatalf("decoded %d auctions: %v", count, err)
+			}
+		})
+	})
+}
+
+// Runs decode b.N times and reports the highest heap and RSS seen while it ran
+// The heap is sampled, RSS is the VmHWM of Linux and is left out on other systems.
+func measurePeakMemory(b *testing.B, decode func()) {
+	runtime.GC()
+	resetPeakRSS := os.WriteFile("/proc/self/clear_refs", []byte("5"), 0) == nil
+
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	baseline := sample[0].Value.Uint64()
+
+	var peak uint64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			metrics.Read(sample)
+			if heap := sample[0].Value.Uint64(); heap > peak {
+				peak = heap
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		decode()
+	}
+
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+
+	if peak > baseline {
+		b.ReportMetric(float64(peak-baseline)/(1<<20), "peak-heap-MB")
+	}
+
+	if rss, err := peakRSS(); resetPeakRSS && err == nil {
+		b.ReportMetric(float64(rss)/(1<<20), "peak-rss-MB")
+	}
+}
+
+// Reads VmHWM, the peak resident set size of the process
+func peakRSS() (int64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 3 && fields[0] == "VmHWM:" && fields[2] == "kB" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			return kb << 10, err
+		}
+	}
+
+	return 0, fmt.Errorf("no VmHWM in /proc/self/status")
+}
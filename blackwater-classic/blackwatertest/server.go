@@ -0,0 +1,268 @@
+// Package blackwatertest provides a fake Blizzard API for tests.
+// Responses are read from the fixture files in the fixtures folder
+package blackwatertest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token handed out by the fake OAuth endpoint
+const (
+	ClientID     = "blackwater-test-id"
+	ClientSecret = "blackwater-test-secret"
+	AccessToken  = "blackwater-test-token"
+)
+
+// Fixture files may contain this placeholder, it is replaced with the URL of the server
+// so that hrefs in the payloads point back to the fake
+const hostPlaceholder = "{{host}}"
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Fake Blizzard API
+//
+// Fixtures are looked up as fixtures/<namespace>/<request path>.json, e.g.
+// fixtures/dynamic-classic1x-eu/data/wow/connected-realm/5284.json
+//
+// Connected realm searches are looked up by the slug of the searched realm name, e.g.
+// fixtures/dynamic-classic1x-eu/data/wow/search/connected-realm/mirage-raceway.json
+//
+// A fixture for the requested locale is preferred if there is one, e.g.
+// fixtures/static-classic1x-eu/data/wow/item/13452.de_DE.json
+type Server struct {
+	*httptest.Server
+
+	fixtures fs.FS
+
+	mu          sync.Mutex
+	requests    []string
+	requestURIs []string
+	failures    map[string][]int
+
+	lastModified          map[string]time.Time
+	ignoreIfModifiedSince bool
+}
+
+// Starts a fake server serving the bundled fixtures
+func NewServer() *Server {
+	sub, err := fs.Sub(fixtures, "fixtures")
+	if err != nil {
+		panic(err)
+	}
+
+	return NewServerFS(sub)
+}
+
+// Starts a fake server serving fixtures from fsys
+func NewServerFS(fsys fs.FS) *Server {
+	s := &Server{fixtures: fsys}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// URL of the fake token endpoint
+func (s *Server) TokenURL() string {
+	return s.URL + "/token"
+}
+
+// Returns the paths of every request the server has received, in order
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Returns the path and query of every request the server has received, in order
+func (s *Server) RequestURIs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requestURIs...)
+}
+
+// Makes the next requests to p fail with the given statuses, one status per request
+// 429 responses ask the client to retry after one second
+func (s *Server) FailNext(p string, statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures == nil {
+		s.failures = make(map[string][]int)
+	}
+
+	s.failures[p] = append(s.failures[p], statuses...)
+}
+
+// Serves p with a Last-Modified header
+// Requests with an If-Modified-Since header that is not before t are answered with 304 Not Modified.
+func (s *Server) SetLastModified(p string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastModified == nil {
+		s.lastModified = make(map[string]time.Time)
+	}
+
+	s.lastModified[p] = t
+}
+
+// Makes the server answer conditional requests in full, like a cache that ignores If-Modified-Since
+func (s *Server) IgnoreIfModifiedSince() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ignoreIfModifiedSince = true
+}
+
+// Returns how many requests have been made to p
+func (s *Server) RequestCount(p string) int {
+	count := 0
+
+	for _, r := range s.Requests() {
+		if r == p {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.Path)
+	s.requestURIs = append(s.requestURIs, r.URL.RequestURI())
+
+	status := 0
+	if failures := s.failures[r.URL.Path]; len(failures) > 0 {
+		status = failures[0]
+		s.failures[r.URL.Path] = failures[1:]
+	}
+	s.mu.Unlock()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	if r.URL.Path == "/token" {
+		s.serveToken(w, r)
+		return
+	}
+
+	if !authorized(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+
+	if namespace == "" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/")
+
+	if p == "data/wow/search/connected-realm" {
+		name := ""
+		for key, values := range query {
+			if strings.HasPrefix(key, "realms.name.") && len(values) > 0 {
+				name = values[0]
+			}
+		}
+
+		p = path.Join(p, slug(name))
+	}
+
+	fixture := path.Join(namespace, p)
+
+	body, err := fs.ReadFile(s.fixtures, fixture+"."+query.Get("locale")+".json")
+	if err != nil {
+		body, err = fs.ReadFile(s.fixtures, fixture+".json")
+	}
+
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	lastModified, ok := s.lastModified[r.URL.Path]
+	ignoreIfModifiedSince := s.ignoreIfModifiedSince
+	s.mu.Unlock()
+
+	if ok {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err == nil && !ignoreIfModifiedSince && !lastModified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	body = bytes.ReplaceAll(body, []byte(hostPlaceholder), []byte(s.URL))
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		var buffer bytes.Buffer
+		zw := gzip.NewWriter(&buffer)
+		zw.Write(body)
+		zw.Close()
+
+		w.Header().Set("Content-Encoding", "gzip")
+		body = buffer.Bytes()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+
+	if r.Method != http.MethodPost || !ok || id != ClientID || secret != ClientSecret {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client","error_description":"Invalid client credentials"}`)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":"%s","token_type":"bearer","expires_in":86399,"sub":"%s"}`, AccessToken, ClientID)
+}
+
+func authorized(r *http.Request) bool {
+	return r.URL.Query().Get("access_token") == AccessToken ||
+		r.Header.Get("Authorization") == "Bearer "+AccessToken
+}
+
+// Writes an error with the same shape as the Blizzard API
+func writeError(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"code":%d,"type":"BLZWEBAPI%08d","detail":"%s"}`, status, status, detail)
+}
+
+func slug(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "-"))
+}
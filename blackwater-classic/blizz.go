@@ -1,55 +1,67 @@
 package blackwater
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/valyala/bytebufferpool"
 	"github.com/valyala/fasthttp"
 )
 
-type Token struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in,omitempty"`
-}
-
 type Client struct {
 	ID     string
 	Secret string
-
-	Token *Token
 }
 
 type Region int
 type Locale string
 type Namespace string
 type Endpoint string
+type GameVersion int
 
 const (
-	Era = iota
-	Wrath
+	Era   GameVersion = iota // Classic Era and its seasonal realms
+	Wrath                    // Progression Classic
 	Retail
 )
 
-const (
-	EU = iota
-	US
-)
+// Progression Classic moves on to the next expansion but keeps its namespaces
+const Cataclysm = Wrath
+
+var GameVersionStrings = [3]string{"era", "classic", "retail"}
+
+// Namespace infix for every game version, e.g. dynamic-classic1x-eu
+var namespaceVersions = [3]string{"-classic1x", "-classic", ""}
 
+// Hosts most regions use, see Regions for the hosts of every region
+// {region} is replaced with the region code, e.g. "eu"
 const (
-	EnGB Locale = "en_GB"
-	EnUS Locale = "en_US"
+	DefaultAPIBaseURL = "https://{region}.api.blizzard.com"
+	DefaultOAuthURL   = "https://oauth.battle.net/token"
 )
 
-var RegionStrings = [2]string{"eu", "us"}
-var NamespaceStrings = [2][2]string{
-	{"dynamic-classic1x-eu", "static-classic1x-eu"},
-	{"dynamic-classic1x-us", "static-classic1x-us"},
+// Optional settings for NewAPIWithOptions
+// Pointing the URLs somewhere else makes it possible to run against a fake Blizzard server
+type APIOptions struct {
+	APIBaseURL string // base URL of the data API, may contain {region}, defaults to the host of the region
+	OAuthURL   string // full URL of the token endpoint, may contain {region}, defaults to the token endpoint of the region
+
+	TokenCachePath     string        // defaults to DefaultTokenCachePath
+	TokenRefreshMargin time.Duration // defaults to DefaultTokenRefreshMargin
+
+	RequestsPerSecond int          // defaults to DefaultRequestsPerSecond
+	RequestsPerHour   int          // defaults to DefaultRequestsPerHour
+	Retry             *RetryPolicy // defaults to DefaultRetryPolicy
 }
 
 type API struct {
@@ -57,11 +69,26 @@ type API struct {
 	httpClient  *fasthttp.Client
 	region      Region
 	locale      Locale
-	gameVersion int
+	gameVersion GameVersion
+	apiBaseURL  string
+	oauthURL    string
+	limiter     *RateLimiter
+	retry       RetryPolicy
+
+	// One token source per OAuth host, China has its own
+	tokensMu           sync.Mutex
+	tokens             map[string]*TokenSource
+	tokenCachePath     string
+	tokenRefreshMargin time.Duration
 }
 
-// Creates a new client
+// Creates a new client that talks to the real Blizzard API
 func NewAPI(clientID string, clientSecret string) (api *API, err error) {
+	return NewAPIWithOptions(clientID, clientSecret, APIOptions{})
+}
+
+// Creates a new client using the given options
+func NewAPIWithOptions(clientID string, clientSecret string, options APIOptions) (api *API, err error) {
 
 	if clientID == "" || clientSecret == "" {
 		return nil, errors.New("Client ID or Client Secret was empty")
@@ -71,6 +98,9 @@ func NewAPI(clientID string, clientSecret string) (api *API, err error) {
 	api.User.ID = clientID
 	api.User.Secret = clientSecret
 
+	api.apiBaseURL = strings.TrimSuffix(options.APIBaseURL, "/")
+	api.oauthURL = options.OAuthURL
+
 	api.httpClient = &fasthttp.Client{
 		NoDefaultUserAgentHeader:      true,
 		DisableHeaderNamesNormalizing: true,
@@ -81,133 +111,185 @@ func NewAPI(clientID string, clientSecret string) (api *API, err error) {
 		}).Dial,
 	}
 
+	perSecond := options.RequestsPerSecond
+	if perSecond == 0 {
+		perSecond = DefaultRequestsPerSecond
+	}
+
+	perHour := options.RequestsPerHour
+	if perHour == 0 {
+		perHour = DefaultRequestsPerHour
+	}
+
+	api.limiter = NewRateLimiter(perSecond, perHour)
+
+	api.retry = DefaultRetryPolicy
+	if options.Retry != nil {
+		api.retry = *options.Retry
+	}
+
 	// Default
 	api.SetRegion(EU, EnGB)
 	log.Printf("Current locale: %s\n", api.locale)
 
-	// Check if token is cached or if it needs to be refreshed
-	f, err := os.OpenFile("blackwater.oauth", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
-	if err != nil {
-		return nil, err
+	api.tokens = make(map[string]*TokenSource)
+
+	api.tokenCachePath = options.TokenCachePath
+	if api.tokenCachePath == "" {
+		api.tokenCachePath = DefaultTokenCachePath
 	}
-	defer f.Close()
 
-	log.Println("Reading from oauth file to see if there is a cached token")
-	fileBuffer, err := io.ReadAll(f)
+	api.tokenRefreshMargin = options.TokenRefreshMargin
+
+	// Fail early if the credentials are wrong
+	_, err = api.Tokens().Token()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(fileBuffer) > 1 {
+	return api, nil
+}
+
+// Returns the token source for the current region
+func (api *API) Tokens() *TokenSource {
+	return api.TokensFor(api.region)
+}
 
-		err = json.Unmarshal(fileBuffer, &api.User.Token)
-		if err == nil {
-			// TODO: Verify that the JSON fields contains any data
-			log.Println("Found a cached oauth token")
+// Returns the token source for a region, regions that share an OAuth host share their tokens
+func (api *API) TokensFor(region Region) *TokenSource {
+	api.tokensMu.Lock()
+	defer api.tokensMu.Unlock()
 
-			tokenExpiration := time.Unix(int64(api.User.Token.ExpiresIn), 0)
-			nowUnix := time.Now().Unix()
+	oauthURL := strings.ReplaceAll(api.oauthURL, "{region}", region.String())
+	if oauthURL == "" {
+		oauthURL = region.Info().OAuthURL
+	}
 
-			// Add a margin of 10 seconds, in case we are unlucky
-			// and we try to fetch with an expired token
+	tokens, ok := api.tokens[oauthURL]
 
-			if nowUnix < (tokenExpiration.Unix() + 10) {
-				log.Println("Token has not yet expired")
-				log.Println("Token will expire:", tokenExpiration)
-				return api, nil
-			}
+	if !ok {
+		// Tokens from other OAuth hosts get their own cache file, e.g. blackwater.oauth.cn
+		cachePath := api.tokenCachePath
+		if oauthURL != DefaultOAuthURL && (api.oauthURL == "" || strings.Contains(api.oauthURL, "{region}")) {
+			cachePath += "." + region.String()
 		}
-	}
 
-	log.Println("Found no token or it expired or something went wrong. Fetching a new token")
+		tokens = NewTokenSource(api.User.ID, api.User.Secret, oauthURL, cachePath)
+		tokens.httpClient = api.httpClient
 
-	req := fasthttp.AcquireRequest()
-	url := fasthttp.AcquireURI()
+		if api.tokenRefreshMargin > 0 {
+			tokens.RefreshMargin = api.tokenRefreshMargin
+		}
 
-	// Set URL
-	url.Parse(nil, []byte("https://oauth.battle.net/token"))
-	url.SetUsername(clientID)
-	url.SetPassword(clientSecret)
-	req.SetURI(url)
-	fasthttp.ReleaseURI(url)
+		api.tokens[oauthURL] = tokens
+	}
 
-	req.Header.SetMethod(fasthttp.MethodPost)
-	req.SetBody([]byte("grant_type=client_credentials"))
+	return tokens
+}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+// Sets the region and the locale that names are returned in
+// An empty locale selects the default locale of the region
+func (api *API) SetRegion(region Region, locale Locale) {
+	if locale == "" {
+		locale = region.Info().DefaultLocale
+	}
 
-	res := fasthttp.AcquireResponse()
-	err = api.httpClient.Do(req, res)
-	fasthttp.ReleaseRequest(req)
-	if err != nil {
-		return nil, err
+	if !region.SupportsLocale(locale) {
+		log.Printf("Locale %s is not served in region %s\n", locale, region)
 	}
 
-	err = json.Unmarshal(res.Body(), &api.User.Token)
-	fasthttp.ReleaseResponse(res)
+	api.region = region
+	api.locale = locale
+}
+
+func (api *API) Region() Region {
+	return api.region
+}
 
-	if err != nil {
-		return nil, err
-	}
+func (api *API) Locale() Locale {
+	return api.locale
+}
 
-	api.User.Token.ExpiresIn += int(time.Now().Unix())
+func (api *API) SetGameVersion(gv GameVersion) {
+	api.gameVersion = gv
+}
 
-	log.Println("Newly fetched oauth token will expire at:", time.Unix(int64(api.User.Token.ExpiresIn), 0))
+func (api *API) GameVersion() GameVersion {
+	return api.gameVersion
+}
 
-	j, _ := json.Marshal(api.User.Token)
-	_, err = f.Write(j)
-	if err != nil {
-		return nil, err
+func (gv GameVersion) String() string {
+	if gv < 0 || int(gv) >= len(GameVersionStrings) {
+		return fmt.Sprintf("GameVersion(%d)", int(gv))
 	}
 
-	return
+	return GameVersionStrings[gv]
 }
 
-func (api *API) SetRegion(region Region, locale Locale) {
-	api.region = region
-	api.locale = locale
+// Parses the names in GameVersionStrings, "wrath" and "cata" are accepted for progression Classic
+func ParseGameVersion(s string) (GameVersion, error) {
+	switch strings.ToLower(s) {
+	case "era", "classic1x", "vanilla":
+		return Era, nil
+	case "classic", "progression", "wrath", "cata", "cataclysm":
+		return Wrath, nil
+	case "retail":
+		return Retail, nil
+	}
+
+	return Era, fmt.Errorf("unknown game version %q, expected era, classic or retail", s)
 }
 
-func (api *API) SetGameVersion(gv int) {
-	api.gameVersion = gv
+// Returns the namespace of the current region and game version
+// kind is either "dynamic" or "static"
+func (api *API) namespace(kind string) string {
+	return api.region.Namespace(kind, api.gameVersion)
+}
+
+// Returns the data API host for the current region
+func (api *API) baseURL() string {
+	if api.apiBaseURL == "" {
+		return api.region.Info().APIBaseURL
+	}
+
+	return strings.ReplaceAll(api.apiBaseURL, "{region}", api.region.String())
 }
 
 func (api *API) buildUrlDynamic(endpoint string) string {
 	return fmt.Sprintf(
-		"https://%s.api.blizzard.com/%s?namespace=%s&locale=%s&access_token=%s",
-		RegionStrings[api.region],
+		"%s/%s?namespace=%s&locale=%s",
+		api.baseURL(),
 		endpoint,
-		NamespaceStrings[api.region][0],
-		api.locale,
-		api.User.Token.AccessToken)
+		api.namespace("dynamic"),
+		api.locale)
 }
 
 func (api *API) buildUrlDynamicWithParameters(endpoint string, parameters string) string {
 	return fmt.Sprintf(
-		"https://%s.api.blizzard.com/%s?namespace=%s&locale=%s&%s&access_token=%s",
-		RegionStrings[api.region],
+		"%s/%s?namespace=%s&locale=%s&%s",
+		api.baseURL(),
 		endpoint,
-		NamespaceStrings[api.region][0],
+		api.namespace("dynamic"),
 		api.locale,
-		parameters,
-		api.User.Token.AccessToken)
+		parameters)
 }
 
 func (api *API) buildUrlStatic(endpoint string) string {
 	return fmt.Sprintf(
-		"https://%s.api.blizzard.com/%s?namespace=%s&locale=%s&access_token=%s",
-		RegionStrings[api.region],
+		"%s/%s?namespace=%s&locale=%s",
+		api.baseURL(),
 		endpoint,
-		NamespaceStrings[api.region][1],
-		api.locale,
-		api.User.Token.AccessToken)
+		api.namespace("static"),
+		api.locale)
 }
 
 // Following types are used for JSON unmarshaling
 
 // This is not an archetype used for Blizzard Web API
 // This is used to unmarshal from [eu|us]-servers.json files
+// Locale is optional and selects the language the realm names are written in, e.g. de_DE
 type ServersJson struct {
+	Locale  string `json:"locale"`
 	Servers []struct {
 		Name   string   `json:"name"`
 		Houses []string `json:"houses"`
@@ -215,171 +297,413 @@ type ServersJson struct {
 }
 
 func (api *API) fetchData(u string) (*fasthttp.Response, error) {
+	res, err := api.do(api.region, u, false, time.Time{})
 
-	// Parse the URL
-	{
-		parsedURL, err := url.Parse(u)
-		if err != nil {
-			fmt.Println("Error parsing URL:", err)
-			return nil, err
-		}
-
-		// Remove the access_token parameter
-		q, _ := url.ParseQuery(parsedURL.RawQuery)
-		q.Del("access_token")
-
-		// Reconstruct the URL without the access_token parameter
-		parsedURL.RawQuery = q.Encode()
-		logSafeUrl := parsedURL.String()
-
-		log.Printf("GET on %s\n", logSafeUrl)
+	if err != nil {
+		return nil, err
 	}
 
-	req := fasthttp.AcquireRequest()
-	url := fasthttp.AcquireURI()
+	if res.Header.StatusCode() != fasthttp.StatusOK {
+		err = newAPIError(u, res)
+		fasthttp.ReleaseResponse(res)
+		return nil, err
+	}
 
-	url.Parse(nil, []byte(u))
-	req.SetURI(url)
-	fasthttp.ReleaseURI(url)
+	return res, nil
 
-	req.Header.SetMethod(fasthttp.MethodGet)
-	req.Header.Set("Accept", "application/json")
+}
 
-	res := fasthttp.AcquireResponse()
-	err := api.httpClient.Do(req, res)
-	fasthttp.ReleaseRequest(req)
+// If modifiedSince is set the request is conditional, ErrNotModified is returned if the data has not changed since then
+// The request is authorized with a token of region.
+func (api *API) fetchDataCompressed(region Region, u string, modifiedSince time.Time) (*fasthttp.Response, error) {
+	res, err := api.do(region, u, true, modifiedSince)
 
 	if err != nil {
-		fasthttp.ReleaseResponse(res)
 		return nil, err
 	}
 
+	if res.Header.StatusCode() == fasthttp.StatusNotModified {
+		fasthttp.ReleaseResponse(res)
+		return nil, ErrNotModified
+	}
+
 	if res.Header.StatusCode() != fasthttp.StatusOK {
+		err = newAPIError(u, res)
 		fasthttp.ReleaseResponse(res)
-		return nil, errors.New("did not return ok status")
+		return nil, err
 	}
 
 	return res, nil
 
 }
 
-func (api *API) fetchDataCompressed(u string) (*fasthttp.Response, error) {
+// What we know about a response besides its decoded body
+type ResponseInfo struct {
+	Bytes        int       // Size of the body as it was sent, before it was decompressed
+	LastModified time.Time // Zero if the response has no Last-Modified header
+}
+
+// Fetches u and decodes the JSON body into v
+func (api *API) fetchJson(u string, compressed bool, v interface{}) error {
+	_, err := api.fetchJsonInfo(u, compressed, time.Time{}, v)
+	return err
+}
+
+// Same as fetchJson but also returns the size and the Last-Modified time of the response
+// modifiedSince makes compressed requests conditional, see fetchDataCompressed.
+// The response is released before returning, gzipped bodies are decompressed into a pooled buffer
+func (api *API) fetchJsonInfo(u string, compressed bool, modifiedSince time.Time, v interface{}) (ResponseInfo, error) {
+	var res *fasthttp.Response
+	var err error
+	var info ResponseInfo
+
+	if compressed {
+		res, err = api.fetchDataCompressed(api.region, u, modifiedSince)
+	} else {
+		res, err = api.fetchData(u)
+	}
+
+	if err != nil {
+		return info, err
+	}
+
+	defer fasthttp.ReleaseResponse(res)
+
+	body := res.Body()
+	info = responseInfo(res)
+
+	if bytes.EqualFold(res.Header.ContentEncoding(), []byte("gzip")) {
+		buffer := bytebufferpool.Get()
+		defer bytebufferpool.Put(buffer)
+
+		_, err = fasthttp.WriteGunzip(buffer, body)
+		if err != nil {
+			return info, &DecodeError{URL: logSafeURL(u), Err: err}
+		}
+
+		body = buffer.B
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return info, &DecodeError{URL: logSafeURL(u), Err: err}
+	}
+
+	return info, nil
+}
+
+func responseInfo(res *fasthttp.Response) ResponseInfo {
+	info := ResponseInfo{Bytes: len(res.Body())}
 
-	// Parse the URL
-	{
-		parsedURL, err := url.Parse(u)
+	if lastModified := res.Header.Peek(fasthttp.HeaderLastModified); len(lastModified) > 0 {
+		info.LastModified, _ = http.ParseTime(string(lastModified))
+	}
+
+	return info
+}
+
+// Returns u without the access_token parameter
+func logSafeURL(u string) string {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	q := parsedURL.Query()
+	q.Del("access_token")
+	parsedURL.RawQuery = q.Encode()
+
+	return parsedURL.String()
+}
+
+// Sends a GET request authorized with a token from the token source of region
+// Every attempt waits for the rate limiter. If the token is rejected, the request is sent
+// once more with a fresh token. 429, 5xx and network errors are retried according to the retry policy.
+// If modifiedSince is set it is sent as If-Modified-Since.
+func (api *API) do(region Region, u string, compressed bool, modifiedSince time.Time) (*fasthttp.Response, error) {
+
+	log.Printf("GET on %s\n", logSafeURL(u))
+
+	renewedToken := false
+
+	for attempt := 0; ; attempt++ {
+		tokens := api.TokensFor(region)
+
+		token, err := tokens.Token()
 		if err != nil {
-			fmt.Println("Error parsing URL:", err)
 			return nil, err
 		}
 
-		// Remove the access_token parameter
-		q, _ := url.ParseQuery(parsedURL.RawQuery)
-		q.Del("access_token")
+		api.limiter.Wait()
+
+		req := fasthttp.AcquireRequest()
+		url := fasthttp.AcquireURI()
+
+		url.Parse(nil, []byte(u))
+		req.SetURI(url)
+		fasthttp.ReleaseURI(url)
+
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		if compressed {
+			req.Header.Set("Accept-Encoding", "gzip")
+		} else {
+			req.Header.Set("Accept", "application/json")
+		}
+
+		if !modifiedSince.IsZero() {
+			req.Header.Set(fasthttp.HeaderIfModifiedSince, modifiedSince.UTC().Format(http.TimeFormat))
+		}
+
+		res := fasthttp.AcquireResponse()
+		err = api.httpClient.Do(req, res)
+		fasthttp.ReleaseRequest(req)
+
+		lastAttempt := attempt+1 >= api.retry.MaxAttempts
+
+		if err != nil {
+			fasthttp.ReleaseResponse(res)
+
+			if lastAttempt {
+				return nil, err
+			}
+
+			wait := api.retry.backoff(attempt, 0)
+			log.Printf("Request failed: %q, retrying in %s\n", err, wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		status := res.Header.StatusCode()
 
-		// Reconstruct the URL without the access_token parameter
-		parsedURL.RawQuery = q.Encode()
-		logSafeUrl := parsedURL.String()
+		if status == fasthttp.StatusUnauthorized && !renewedToken {
+			log.Println("The oauth token was rejected, fetching a new one")
+			fasthttp.ReleaseResponse(res)
+			tokens.Invalidate(token.AccessToken)
+			renewedToken = true
+			attempt--
+			continue
+		}
+
+		if retryableStatus(status) && !lastAttempt {
+			wait := api.retry.backoff(attempt, parseRetryAfter(string(res.Header.Peek("Retry-After"))))
+			fasthttp.ReleaseResponse(res)
 
-		log.Printf("GET on %s\n", logSafeUrl)
+			log.Printf("Got status %d, retrying in %s\n", status, wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		return res, nil
+	}
+}
+
+// Fetches a href found in another response and decodes the JSON body into v
+func (api *API) FetchFromHref(href string, v interface{}) error {
+	if len(href) == 0 {
+		return ErrEmptyHref
 	}
 
-	req := fasthttp.AcquireRequest()
-	url := fasthttp.AcquireURI()
+	return api.fetchJson(href, false, v)
+}
 
-	url.Parse(nil, []byte(u))
-	req.SetURI(url)
-	fasthttp.ReleaseURI(url)
+// Same as FetchFromHref but asks for a gzipped response
+func (api *API) FetchCompressedFromHref(href string, v interface{}) error {
+	if len(href) == 0 {
+		return ErrEmptyHref
+	}
 
-	req.Header.SetMethod(fasthttp.MethodGet)
-	req.Header.Set("Accept-Encoding", "gzip")
+	return api.fetchJson(href, true, v)
+}
 
-	res := fasthttp.AcquireResponse()
-	err := api.httpClient.Do(req, res)
-	fasthttp.ReleaseRequest(req)
+// Fetches the auction house index that a connected realm links to
+func (api *API) ClassicAuctionHouseIndexFromHref(href string) (*AuctionHouseMetaDataJson, error) {
+	var index AuctionHouseMetaDataJson
+	err := api.FetchFromHref(href, &index)
 
 	if err != nil {
-		fasthttp.ReleaseResponse(res)
 		return nil, err
 	}
 
-	if res.Header.StatusCode() != fasthttp.StatusOK {
-		return nil, errors.New(res.String())
-	}
+	return &index, nil
+}
 
-	return res, nil
+// Fetches the auctions of an auction house, href is taken from the auction house index
+// or from the connected realm on Retail
+func (api *API) AuctionsFromHref(href string) (*AuctionJson, error) {
+	var auctions AuctionJson
+	err := api.FetchCompressedFromHref(href, &auctions)
+
+	if err != nil {
+		return nil, err
+	}
 
+	return &auctions, nil
 }
 
-func (api *API) FetchFromHref(href string) (*fasthttp.Response, error) {
+// Same as AuctionsFromHref but also returns the size and the Last-Modified time of the dump
+// If modifiedSince is set and the dump has not changed since then, ErrNotModified is returned.
+func (api *API) AuctionsFromHrefSince(href string, modifiedSince time.Time) (*AuctionJson, ResponseInfo, error) {
+	var auctions AuctionJson
+
 	if len(href) == 0 {
-		return nil, errors.New("href is empty")
+		return nil, ResponseInfo{}, ErrEmptyHref
 	}
 
-	return api.fetchData(fmt.Sprintf(
-		"%s&access_token=%s",
-		href,
-		api.User.Token.AccessToken))
+	info, err := api.fetchJsonInfo(href, true, modifiedSince, &auctions)
+
+	if err != nil {
+		return nil, info, err
+	}
+
+	return &auctions, info, nil
 }
 
-func (api *API) FetchCompressedFromHref(href string) (*fasthttp.Response, error) {
-	if len(href) == 0 {
-		return nil, errors.New("href is empty")
+// An auction house dump as it was downloaded
+// It is decompressed and decoded while it is read, see Reader and DecodeAuctions.
+type AuctionDump struct {
+	URL     string // without the access token
+	Body    []byte
+	Gzipped bool
+}
+
+// Returns the JSON of the dump, decompressing it as it is read
+func (dump *AuctionDump) Reader() (io.Reader, error) {
+	if !dump.Gzipped {
+		return bytes.NewReader(dump.Body), nil
 	}
 
-	return api.fetchDataCompressed(fmt.Sprintf(
-		"%s&access_token=%s",
-		href,
-		api.User.Token.AccessToken))
+	return gzip.NewReader(bytes.NewReader(dump.Body))
 }
 
-func (api *API) ConnectedRealmsIndex() (*fasthttp.Response, error) {
+// Downloads the auctions of an auction house without decoding them
+// The href is fetched with a token of region, the region of the house, whatever the current region of the API is.
+// If modifiedSince is set and the dump has not changed since then, ErrNotModified is returned.
+func (api *API) AuctionDumpFromHrefSince(region Region, href string, modifiedSince time.Time) (*AuctionDump, ResponseInfo, error) {
+	if len(href) == 0 {
+		return nil, ResponseInfo{}, ErrEmptyHref
+	}
+
+	res, err := api.fetchDataCompressed(region, href, modifiedSince)
+
+	if err != nil {
+		return nil, ResponseInfo{}, err
+	}
 
-	res, err := api.fetchData(
-		api.buildUrlDynamic("data/wow/connected-realm/index"))
+	defer fasthttp.ReleaseResponse(res)
 
-	return res, err
+	dump := &AuctionDump{
+		URL:     logSafeURL(href),
+		Body:    append([]byte(nil), res.Body()...),
+		Gzipped: bytes.EqualFold(res.Header.ContentEncoding(), []byte("gzip")),
+	}
 
+	return dump, responseInfo(res), nil
 }
 
-func (api *API) ConnectedRealm(connectedRealmID int) (*fasthttp.Response, error) {
+func (api *API) ConnectedRealmsIndex() (*ConnectedRealmsIndexJson, error) {
+	var index ConnectedRealmsIndexJson
+	err := api.fetchJson(api.buildUrlDynamic("data/wow/connected-realm/index"), false, &index)
 
-	res, err := api.fetchData(
-		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d", connectedRealmID)))
+	if err != nil {
+		return nil, err
+	}
 
-	return res, err
+	return &index, nil
+}
+
+func (api *API) ConnectedRealm(connectedRealmID int) (*ConnectedRealmJson, error) {
+	var realm ConnectedRealmJson
+	err := api.fetchJson(
+		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d", connectedRealmID)), false, &realm)
 
+	if err != nil {
+		return nil, err
+	}
+
+	return &realm, nil
 }
 
-func (api *API) ConnectedRealmSearch(parameters string) (*fasthttp.Response, error) {
+func (api *API) ConnectedRealmSearch(parameters string) (*ConnectedRealmSearchJson, error) {
+	var search ConnectedRealmSearchJson
+	err := api.fetchJson(
+		api.buildUrlDynamicWithParameters("data/wow/search/connected-realm", parameters), false, &search)
 
-	res, err := api.fetchData(
-		api.buildUrlDynamicWithParameters("data/wow/search/connected-realm", parameters))
+	if err != nil {
+		return nil, err
+	}
 
-	return res, err
+	return &search, nil
+}
+
+// Fetches the auctions of a connected realm using the endpoint of the current game version
+// Classic has one auction house per faction plus a neutral one, auctionHouseID is ignored for Retail
+func (api *API) Auctions(connectedRealmID int, auctionHouseID int) (*AuctionJson, error) {
+	if api.gameVersion == Retail {
+		return api.RetailAuctions(connectedRealmID)
+	}
 
+	return api.ClassicAuctions(connectedRealmID, auctionHouseID)
 }
 
-func (api *API) ClassicAuctions(connectedRealmID int, auctionHouseID int) (*fasthttp.Response, error) {
+// Retail has a single auction house per connected realm
+func (api *API) RetailAuctions(connectedRealmID int) (*AuctionJson, error) {
+	var auctions AuctionJson
+	err := api.fetchJson(
+		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d/auctions", connectedRealmID)), true, &auctions)
 
-	res, err := api.fetchDataCompressed(
-		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d/auctions/%d", connectedRealmID, auctionHouseID)))
+	if err != nil {
+		return nil, err
+	}
 
-	return res, err
+	return &auctions, nil
+}
 
+// Fetches the commodity market of the current region
+// Commodities only exist on Retail, the game version has to be set to Retail
+func (api *API) Commodities() (*CommoditiesJson, error) {
+	var commodities CommoditiesJson
+	err := api.fetchJson(api.buildUrlDynamic("data/wow/auctions/commodities"), true, &commodities)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &commodities, nil
 }
 
-func (api *API) ClassicAuctionHouseIndex(realmID int) (*fasthttp.Response, error) {
+func (api *API) ClassicAuctions(connectedRealmID int, auctionHouseID int) (*AuctionJson, error) {
+	var auctions AuctionJson
+	err := api.fetchJson(
+		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d/auctions/%d", connectedRealmID, auctionHouseID)), true, &auctions)
 
-	res, err := api.fetchDataCompressed(
-		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d/auctions/index", realmID)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &auctions, nil
+}
+
+func (api *API) ClassicAuctionHouseIndex(realmID int) (*AuctionHouseMetaDataJson, error) {
+	var index AuctionHouseMetaDataJson
+	err := api.fetchJson(
+		api.buildUrlDynamic(fmt.Sprintf("data/wow/connected-realm/%d/auctions/index", realmID)), true, &index)
 
-	return res, err
+	if err != nil {
+		return nil, err
+	}
 
+	return &index, nil
 }
 
-func (api *API) ClassicItem(itemID int) (*fasthttp.Response, error) {
-	res, err := api.fetchData(api.buildUrlStatic(fmt.Sprintf("data/wow/item/%d", itemID)))
+func (api *API) ClassicItem(itemID int) (*ItemJson, error) {
+	var item ItemJson
+	err := api.fetchJson(api.buildUrlStatic(fmt.Sprintf("data/wow/item/%d", itemID)), false, &item)
+
+	if err != nil {
+		return nil, err
+	}
 
-	return res, err
+	return &item, nil
 }
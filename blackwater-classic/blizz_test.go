@@ -0,0 +1,256 @@
+package blackwater
+
+import (
+	"blackwater/blackwater-classic/blackwatertest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTypedResponses(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{})
+
+	search, err := api.ConnectedRealmSearch("status.type=UP&realms.name.en_GB=Firemaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(search.Results) != 1 || search.Results[0].Data.ID != 4467 {
+		t.Fatalf("unexpected search result: %+v", search)
+	}
+
+	realm, err := api.ConnectedRealm(4467)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if realm.ID != 4467 || len(realm.Realms) != 1 || realm.Realms[0].Name != "Firemaw" || realm.Auctions.Href == "" {
+		t.Fatalf("unexpected connected realm: %+v", realm)
+	}
+
+	index, err := api.ClassicAuctionHouseIndexFromHref(realm.Auctions.Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Auctions) != 3 {
+		t.Fatalf("expected 3 auction houses, got %d", len(index.Auctions))
+	}
+
+	// Gzipped by the server and decompressed by the client
+	auctions, err := api.ClassicAuctions(4467, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(auctions.Auctions) != 4 {
+		t.Fatalf("expected 4 auctions, got %d", len(auctions.Auctions))
+	}
+
+	fromHref, err := api.AuctionsFromHref(index.Auctions[2].Key.Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fromHref.Auctions) != 4 || fromHref.Auctions[0].ID != auctions.Auctions[0].ID {
+		t.Errorf("auctions from href do not match: %+v", fromHref)
+	}
+
+	item, err := api.ClassicItem(15993)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Name != "Thorium Grenade" || item.ItemClass.Name != "Trade Goods" || item.ItemSubClass.ID != 2 {
+		t.Errorf("unexpected item: %+v", item)
+	}
+}
+
+func TestLocalizedString(t *testing.T) {
+	var index AuctionHouseMetaDataJson
+
+	err := json.Unmarshal([]byte(`{"auctions": [
+		{"id": 2, "name": "Alliance Auction House"},
+		{"id": 6, "name": {"de_DE": "Auktionshaus der Horde", "en_GB": "Horde Auction House"}},
+		{"id": 7, "name": {"fr_FR": "Hôtel des ventes de la Voile sanglante"}}
+	]}`), &index)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		house    int
+		locale   Locale
+		expected string
+	}{
+		{0, DeDE, "Alliance Auction House"},
+		{1, DeDE, "Auktionshaus der Horde"},
+		{1, FrFR, "Horde Auction House"},
+		{2, EnGB, "Hôtel des ventes de la Voile sanglante"},
+	}
+
+	for _, test := range tests {
+		if name := index.Auctions[test.house].Name.In(test.locale); name != test.expected {
+			t.Errorf("house %d in %s: got %q, expected %q", test.house, test.locale, name, test.expected)
+		}
+	}
+}
+
+func TestAuctionsModifiedSince(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{})
+
+	realm, err := api.ConnectedRealm(4467)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := api.ClassicAuctionHouseIndexFromHref(realm.Auctions.Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	href := index.Auctions[0].Key.Href
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server.SetLastModified("/data/wow/connected-realm/4467/auctions/2", modified)
+
+	auctions, info, err := api.AuctionsFromHrefSince(href, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(auctions.Auctions) != 2 || !info.LastModified.Equal(modified) || info.Bytes == 0 {
+		t.Fatalf("unexpected response: %d auctions, %+v", len(auctions.Auctions), info)
+	}
+
+	if _, _, err = api.AuctionsFromHrefSince(href, modified); !errors.Is(err, ErrNotModified) {
+		t.Errorf("expected ErrNotModified, got %v", err)
+	}
+
+	if _, _, err = api.AuctionsFromHrefSince(href, modified.Add(-time.Minute)); err != nil {
+		t.Errorf("expected the auctions modified after If-Modified-Since, got %v", err)
+	}
+}
+
+func TestAuctionDumpRegions(t *testing.T) {
+	// Every region has its own OAuth host, like China, and only takes its own tokens
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+		if rest == "token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"%s-token","token_type":"bearer","expires_in":86399}`, region)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+region+"-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"code":401,"type":"BLZWEBAPI00000401","detail":"Unauthorized"}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"auctions": []}`)
+	}))
+	defer server.Close()
+
+	api, err := NewAPIWithOptions("id", "secret", APIOptions{
+		APIBaseURL:     server.URL,
+		OAuthURL:       server.URL + "/{region}/token",
+		TokenCachePath: filepath.Join(t.TempDir(), "blackwater.oauth"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Workers of one run fetch houses of both regions at the same time, the API stays in EU
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+
+	for i, region := range []Region{EU, CN} {
+		wg.Add(1)
+
+		go func(i int, region Region) {
+			defer wg.Done()
+
+			href := fmt.Sprintf("%s/%s/data/wow/connected-realm/1/auctions", server.URL, region)
+			_, _, errs[i] = api.AuctionDumpFromHrefSince(region, href, time.Time{})
+		}(i, region)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("house %d: %v", i, err)
+		}
+	}
+
+	if api.Region() != EU {
+		t.Errorf("expected the API to stay in EU, got %s", api.Region())
+	}
+}
+
+func TestTypedResponseErrors(t *testing.T) {
+	server := blackwatertest.NewServerFS(fstest.MapFS{
+		"static-classic1x-eu/data/wow/item/1.json": {Data: []byte(`{"name": "Broken`)},
+	})
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{})
+
+	_, err := api.ClassicItem(1)
+
+	var decodeError *DecodeError
+	if !errors.As(err, &decodeError) {
+		t.Errorf("expected a DecodeError, got %v", err)
+	}
+
+	if _, err = api.AuctionsFromHref(""); !errors.Is(err, ErrEmptyHref) {
+		t.Errorf("expected ErrEmptyHref, got %v", err)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	api := &API{}
+
+	expected := map[GameVersion][2]string{
+		Era:    {"dynamic-classic1x-us", "static-classic1x-us"},
+		Wrath:  {"dynamic-classic-us", "static-classic-us"},
+		Retail: {"dynamic-us", "static-us"},
+	}
+
+	for gameVersion, namespaces := range expected {
+		api.SetRegion(US, EnUS)
+		api.SetGameVersion(gameVersion)
+
+		if ns := api.namespace("dynamic"); ns != namespaces[0] {
+			t.Errorf("%s: expected %s, got %s", gameVersion, namespaces[0], ns)
+		}
+
+		if ns := api.namespace("static"); ns != namespaces[1] {
+			t.Errorf("%s: expected %s, got %s", gameVersion, namespaces[1], ns)
+		}
+	}
+
+	if gv, err := ParseGameVersion("cata"); err != nil || gv != Cataclysm {
+		t.Errorf("expected cata to parse as progression Classic, got %v %v", gv, err)
+	}
+
+	if _, err := ParseGameVersion("mop"); err == nil {
+		t.Error("expected an error for an unknown game version")
+	}
+}
@@ -0,0 +1,90 @@
+package blackwater
+
+import (
+	"log"
+)
+
+var commodityInsertColumns = []string{
+	"auction_id", "region", "timestamp",
+	"item_id", "quantity", "unit_price", "time_left",
+}
+
+var commodityKeyColumns = []string{"auction_id", "region", "timestamp"}
+
+// Stores a snapshot of the region wide commodity market
+// Commodities are only sold on Retail, every region shares one market for them
+func InsertCommodities(db *Database, commoditiesJson CommoditiesJson, importTime int64, region Region) error {
+
+	insertCommodity := db.Dialect().Upsert("Commodities", commodityInsertColumns, commodityKeyColumns)
+
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(insertCommodity)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	counter := 0
+	commitSize := 10000
+
+	// Every row of a snapshot has the same import time
+	timestamp := db.Dialect().timestamp(importTime)
+
+	for _, commodity := range commoditiesJson.Auctions {
+
+		_, err = stmt.Exec(commodity.ID, region, timestamp,
+			commodity.Item.ID, commodity.Quantity, commodity.UnitPrice, commodity.TimeLeft)
+
+		if err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
+		}
+
+		counter++
+
+		if counter >= commitSize {
+
+			stmt.Close()
+
+			err = tx.Commit()
+			if err != nil {
+				log.Println("Could not commit")
+				return err
+			}
+			tx, err = db.Handle.Begin()
+			if err != nil {
+				log.Println("Could not begin")
+				return err
+			}
+
+			stmt, err = tx.Prepare(insertCommodity)
+
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			counter = 0
+		}
+
+	}
+
+	stmt.Close()
+
+	err = tx.Commit()
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("Finished importing commodities to DB.")
+
+	return nil
+}
@@ -8,16 +8,22 @@ import (
 type Database struct {
 	DatabaseType     string
 	ConnectionString string
+	AuctionStorage   AuctionStorage // Full unless it is set
 	Handle           *sql.DB
 }
 
+// One auction house of a connected realm
 type AuctionColumns struct {
 	ConnectedRealmID int
 	Region           int
+	GameVersion      GameVersion
 	Name             string
-	AllianceHref     string
-	HordeHref        string
-	NeutralHref      string
+	AuctionHouseID   int
+	AuctionHouseName string
+	FactionID        sql.NullInt64 // NULL if we don't know which faction the house belongs to
+	Href             string
+	Enabled          bool
+	LastModified     NullTimestamp // Last-Modified of the last dump we imported, NULL if there is none yet
 }
 
 type ItemColumns struct {
@@ -30,134 +36,30 @@ type ItemColumns struct {
 	Name           string
 }
 
+// Creates the tables by applying every pending migration
 func SetupDatabase(db *Database) error {
 
-	handle, err := sql.Open(db.DatabaseType, db.ConnectionString)
+	_, err := ParseDialect(db.DatabaseType)
 	if err != nil {
 		return err
 	}
 
-	defer handle.Close()
-
-	log.Print("Created a handle to the DB.")
-
-	/*
-		region := { EU = 0, US = 1}
-	*/
-	_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS ConnectedRealms(
-		connected_realm_id INTEGER NOT NULL PRIMARY KEY,
-		region INTEGER,
-		name TEXT,
-		timezone TEXT,
-		alliance_ah_href TEXT,
-		horde_ah_href TEXT,
-		neutral_ah_href TEXT);`)
-
-	if err != nil {
-		return err
-	}
-
-	/*
-
-		Item Class ID: Weapon, head, shoulder, etc
-		Item Subclass ID: Stave, Sword etc.
-		Example:
-		Item Class ID: Consumable
-		Item Subclass ID: Elixir, potion, enchant, flask, ....
-
-	*/
-	_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS Items(
-		item_id INTEGER NOT NULL PRIMARY KEY,
-		item_class_id INTEGER,
-		item_class STRING,
-		item_subclass_id INTEGER,
-		item_subclass STRING,
-		quality STRING,
-		name TEXT);`)
-
-	if err != nil {
-		return err
-	}
-
-	log.Println("Created Items table")
-
-	_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS Factions(
-		faction_id INTEGER PRIMARY KEY,
-		faction_name TEXT);`)
-
+	handle, err := sql.Open(db.DatabaseType, db.ConnectionString)
 	if err != nil {
 		return err
 	}
 
-	_, err = handle.Exec(`INSERT INTO Factions(faction_id, faction_name) VALUES(0, "Alliance")`)
-	if err != nil {
-		log.Println(err)
-	}
-
-	_, err = handle.Exec(`INSERT INTO Factions(faction_id, faction_name) VALUES(1, "Horde")`)
-	if err != nil {
-		log.Println(err)
-	}
+	defer handle.Close()
 
-	_, err = handle.Exec(`INSERT INTO Factions(faction_id, faction_name) VALUES(2, "Neutral")`)
-	if err != nil {
-		log.Println(err)
-	}
+	log.Print("Created a handle to the DB.")
 
-	log.Println("Created factions table")
-
-	_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS Auctions(
-		id INTEGER NOT NULL PRIMARY KEY,
-		auction_id INTEGER,
-		buyout INTEGER,
-		quantity INTEGER,
-		time_left TEXT,
-		timestamp DATETIME,
-		item_id INTEGER,
-		connected_realm_id INTEGER,
-		faction_id INTEGER,
-		FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
-		FOREIGN KEY(item_id) REFERENCES Items(item_id),
-		FOREIGN KEY(faction_id) REFERENCES Factions(faction_id),
-		UNIQUE(auction_id, connected_realm_id, time_left));`)
+	applied, err := MigrateUp(&Database{DatabaseType: db.DatabaseType, ConnectionString: db.ConnectionString, Handle: handle})
 
 	if err != nil {
 		return err
 	}
 
-	log.Println("Created Auctions table")
-
-	/*
-			_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS Stats(
-				id INTEGER NOT NULL PRIMARY KEY,
-				item_id INTEGER,
-				connected_realm_id INTEGER,
-				mean_price INTEGER,
-				median_price INTEGER,
-				min_price INTEGER,
-				FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
-				FOREIGN KEY(item_id) REFERENCES Items(item_id))`)
-
-			if err != nil {
-				return err
-			}
-
-
-		_, err = handle.Exec(`CREATE TABLE IF NOT EXISTS WeeklySeries(
-				id INTEGER NOT NULL PRIMARY KEY,
-				item_id INTEGER,
-				connected_realm_id INTEGER,
-				mean_price INTEGER,
-				median_price INTEGER,
-				min_price INTEGER,
-				FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
-				FOREIGN KEY(item_id) REFERENCES Items(item_id))`)
-
-		if err != nil {
-			return err
-		}
-
-	*/
+	log.Printf("Applied %d migrations\n", len(applied))
 
 	return nil
 }
@@ -171,26 +73,24 @@ func NewLocalDatabase(p string) (db Database) {
 }
 
 func (db *Database) OpenConnection() (err error) {
-	err = nil
+	_, err = ParseDialect(db.DatabaseType)
+
+	if err != nil {
+		return
+	}
 
 	db.Handle, err = sql.Open(db.DatabaseType, db.ConnectionString)
 
 	return
 }
 
-func (db *Database) CloseConnection() {
-	db.Handle.Close()
-}
-
-func OpenDB(dbPath string) (*sql.DB, error) {
-	log.Println("Opening a handle to SQL DB.")
-
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
-	}
+// The dialect of DatabaseType, OpenConnection makes sure it is a known one
+func (db *Database) Dialect() Dialect {
+	dialect, _ := ParseDialect(db.DatabaseType)
 
-	log.Print("Created a handle to the DB.")
+	return dialect
+}
 
-	return db, nil
+func (db *Database) CloseConnection() {
+	db.Handle.Close()
 }
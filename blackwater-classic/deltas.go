@@ -0,0 +1,707 @@
+package blackwater
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How the auctions of a snapshot are stored
+// Both keep AuctionLifecycles, HouseAt reads the auctions of a snapshot from Auctions or AuctionChanges.
+type AuctionStorage int
+
+const (
+	// Auctions gets a row per auction and time_left bucket
+	FullStorage AuctionStorage = iota
+	// Auctions are only written once, to AuctionLifecycles, and then only their changes
+	DeltaStorage
+)
+
+// Indexed by AuctionStorage, these are also the values of the -storage flag
+var AuctionStorageStrings = []string{"full", "delta"}
+
+func (s AuctionStorage) String() string {
+	if s < 0 || int(s) >= len(AuctionStorageStrings) {
+		return fmt.Sprintf("AuctionStorage(%d)", int(s))
+	}
+
+	return AuctionStorageStrings[s]
+}
+
+func ParseAuctionStorage(storage string) (AuctionStorage, error) {
+	for i, name := range AuctionStorageStrings {
+		if strings.EqualFold(name, storage) {
+			return AuctionStorage(i), nil
+		}
+	}
+
+	return FullStorage, fmt.Errorf("unknown auction storage %q", storage)
+}
+
+// The table the auctions of a snapshot are written to before TrackLifecycles reads them back
+func (db *Database) auctionTable() string {
+	if db.AuctionStorage == DeltaStorage {
+		return "PendingAuctions"
+	}
+
+	return "Auctions"
+}
+
+var auctionChangeColumns = []string{"connected_realm_id", "auction_id", "changed_at", "auction_house_id", "snapshot_id", "time_left", "bid"}
+
+var auctionChangeKeyColumns = []string{"connected_realm_id", "auction_id", "changed_at"}
+
+// Rebuilds the auctions a house had at the latest complete snapshot at or before at, a unix time
+// The auctions are what the dump of the snapshot had, as far as Auctions, AuctionLifecycles and AuctionChanges know.
+// Auctions keeps a row per time_left bucket, so the auctions of full snapshots have the last bid of their bucket.
+// Returns ErrNoSnapshot if the house has no complete snapshot that early.
+func HouseAt(db *Database, connectedRealmID int, auctionHouseID int, at int64) (*Snapshot, []AuctionListingJson, error) {
+	dialect := db.Dialect()
+
+	snapshot := &Snapshot{ConnectedRealmID: connectedRealmID, AuctionHouseID: auctionHouseID, Status: SnapshotComplete}
+	var fetchedAt NullTimestamp
+
+	err := db.Handle.QueryRow(dialect.Rebind(`SELECT snapshot_id, fetched_at, faction_id, game_version FROM Snapshots
+		WHERE connected_realm_id = ? AND auction_house_id = ? AND status = ? AND fetched_at <= ?
+		ORDER BY fetched_at DESC, snapshot_id DESC LIMIT 1`),
+		connectedRealmID, auctionHouseID, SnapshotComplete, dialect.timestamp(at)).
+		Scan(&snapshot.SnapshotID, &fetchedAt, &snapshot.FactionID, &snapshot.GameVersion)
+
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrNoSnapshot
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshot.FetchedAt = fetchedAt.Time.Unix()
+
+	at = snapshot.FetchedAt
+	listings := map[int]AuctionListingJson{}
+
+	// Delta snapshots: the latest change of every auction of the house up to the snapshot, unless it was the one that ended it
+	// Auctions that a full snapshot ended have no change that ends them, their lifecycle has.
+	err = addListings(listings, db, dialect.Rebind(`SELECT L.auction_id, L.item_id, L.item_rand, L.item_seed, L.item_modifiers, L.item_bonus_lists,
+			L.quantity, C.bid, L.buyout, L.unit_price, C.time_left
+		FROM AuctionChanges C
+		JOIN AuctionLifecycles L ON L.connected_realm_id = C.connected_realm_id AND L.auction_id = C.auction_id
+		WHERE C.connected_realm_id = ? AND C.auction_house_id = ? AND C.time_left IS NOT NULL
+		AND C.changed_at = (SELECT MAX(C2.changed_at) FROM AuctionChanges C2
+			WHERE C2.connected_realm_id = C.connected_realm_id AND C2.auction_id = C.auction_id AND C2.changed_at <= ?)
+		AND (L.ended_at IS NULL OR L.ended_at > ?)`),
+		connectedRealmID, auctionHouseID, dialect.timestamp(at), dialect.timestamp(at))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Full snapshots: the rows of Auctions that were listed from before the snapshot until after it
+	// They are what the dump had, so they take the place of changes of the same auctions.
+	err = addListings(listings, db, dialect.Rebind(`SELECT A.auction_id, A.item_id, A.item_rand, A.item_seed, A.item_modifiers, A.item_bonus_lists,
+			A.quantity, A.bid, A.buyout, A.unit_price, A.time_left
+		FROM Auctions A
+		JOIN Snapshots F ON F.snapshot_id = A.snapshot_id
+		JOIN Snapshots L ON L.snapshot_id = A.last_snapshot_id
+		WHERE A.connected_realm_id = ? AND A.auction_house_id = ? AND F.fetched_at <= ? AND L.fetched_at >= ?`),
+		connectedRealmID, auctionHouseID, dialect.timestamp(at), dialect.timestamp(at))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auctions := []AuctionListingJson{}
+
+	for _, auction := range listings {
+		auctions = append(auctions, auction)
+	}
+
+	sort.Slice(auctions, func(i, j int) bool { return auctions[i].ID < auctions[j].ID })
+
+	snapshot.AuctionCount = len(auctions)
+
+	return snapshot, auctions, nil
+}
+
+// Adds the auctions query returns to listings, in place of those that are already there
+// The query selects the columns of an auction in the order HouseAt reads them.
+func addListings(listings map[int]AuctionListingJson, db *Database, query string, args ...interface{}) error {
+	rows, err := db.Handle.Query(query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var auction AuctionListingJson
+		var itemID, rand, seed, quantity, bid, buyout, unitPrice sql.NullInt64
+		var modifiers, bonusLists sql.NullString
+
+		err = rows.Scan(&auction.ID, &itemID, &rand, &seed, &modifiers, &bonusLists,
+			&quantity, &bid, &buyout, &unitPrice, &auction.TimeLeft)
+
+		if err != nil {
+			return err
+		}
+
+		auction.Item.ID = int(itemID.Int64)
+		auction.Item.Rand = int(rand.Int64)
+		auction.Item.Seed = seed.Int64
+		auction.Quantity = int(quantity.Int64)
+		auction.Bid = int(bid.Int64)
+		auction.Buyout = int(buyout.Int64)
+
+		// Only auctions without a buyout had a unit price in their dump, see PricePerUnit
+		if !buyout.Valid {
+			auction.UnitPrice = int(unitPrice.Int64)
+		}
+
+		if modifiers.Valid {
+			err = json.Unmarshal([]byte(modifiers.String), &auction.Item.Modifiers)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if bonusLists.Valid {
+			err = json.Unmarshal([]byte(bonusLists.String), &auction.Item.BonusLists)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		listings[auction.ID] = auction
+	}
+
+	return rows.Err()
+}
+
+// What ConvertAuctions did
+type ConvertSummary struct {
+	Houses    int
+	Snapshots int // Snapshots recreated for auctions that are older than the Snapshots table
+	Auctions  int
+	Changes   int
+	Tracked   int // Auctions that already had a lifecycle, only their changes were added
+	Deleted   int64
+}
+
+func (s ConvertSummary) String() string {
+	return fmt.Sprintf("Converted %d auctions of %d auction houses to %d changes, %d snapshots recreated, %d already tracked, %d rows deleted",
+		s.Auctions, s.Houses, s.Changes, s.Snapshots, s.Tracked, s.Deleted)
+}
+
+// Auctions are read this many rows at a time by ConvertAuctions, each batch is written in its own transaction
+const convertBatchSize = 10000
+
+// Moves the rows of Auctions into AuctionLifecycles and AuctionChanges, so that HouseAt can rebuild the houses they came from
+// Auctions from before the Snapshots table get a complete snapshot per house and import time first.
+//
+// Auctions keeps the first and last snapshot of every time_left bucket, an auction ends with the first complete snapshot after its last.
+// Rows from before that only kept the last snapshot, so the conversion can't be exact for them:
+// a bucket is taken to start with the first complete snapshot after the previous one,
+// an auction to be listed from the last snapshot of its first bucket.
+// Auctions that already have a lifecycle keep it, full storage tracks them without changes, so only those are added.
+// If deleteConverted is set, the rows of Auctions are deleted once their house is converted, delta storage does not need them.
+func ConvertAuctions(db *Database, deleteConverted bool) (ConvertSummary, error) {
+	summary := ConvertSummary{}
+
+	var err error
+	summary.Snapshots, err = recreateSnapshots(db)
+
+	if err != nil {
+		return summary, err
+	}
+
+	rows, err := db.Handle.Query(`SELECT DISTINCT connected_realm_id, auction_house_id FROM Auctions
+		WHERE snapshot_id IS NOT NULL AND connected_realm_id IS NOT NULL AND auction_house_id IS NOT NULL
+		ORDER BY connected_realm_id, auction_house_id`)
+
+	if err != nil {
+		return summary, err
+	}
+
+	houses := [][2]int{}
+
+	for rows.Next() {
+		var house [2]int
+
+		err = rows.Scan(&house[0], &house[1])
+
+		if err != nil {
+			rows.Close()
+			return summary, err
+		}
+
+		houses = append(houses, house)
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+
+	if err != nil {
+		return summary, err
+	}
+
+	for _, house := range houses {
+		err = convertHouse(db, house[0], house[1], &summary)
+
+		if err != nil {
+			return summary, fmt.Errorf("auction house %d of %d: %w", house[1], house[0], err)
+		}
+
+		summary.Houses++
+
+		if !deleteConverted {
+			continue
+		}
+
+		result, err := db.Handle.Exec(db.Dialect().Rebind(`DELETE FROM Auctions
+			WHERE connected_realm_id = ? AND auction_house_id = ? AND snapshot_id IS NOT NULL`), house[0], house[1])
+
+		if err != nil {
+			return summary, err
+		}
+
+		deleted, _ := result.RowsAffected()
+		summary.Deleted += deleted
+	}
+
+	return summary, nil
+}
+
+// Records a complete snapshot for every house and import time of Auctions that has none
+// Returns how many snapshots were recorded.
+func recreateSnapshots(db *Database) (int, error) {
+	dialect := db.Dialect()
+
+	rows, err := db.Handle.Query(`SELECT connected_realm_id, auction_house_id, faction_id, game_version, timestamp, COUNT(*)
+		FROM Auctions
+		WHERE snapshot_id IS NULL AND connected_realm_id IS NOT NULL AND auction_house_id IS NOT NULL AND timestamp IS NOT NULL
+		GROUP BY connected_realm_id, auction_house_id, faction_id, game_version, timestamp
+		ORDER BY timestamp`)
+
+	if err != nil {
+		return 0, err
+	}
+
+	type importRun struct {
+		house     AuctionColumns
+		timestamp NullTimestamp
+		count     int
+	}
+
+	runs := []importRun{}
+
+	for rows.Next() {
+		var run importRun
+
+		err = rows.Scan(&run.house.ConnectedRealmID, &run.house.AuctionHouseID, &run.house.FactionID, &run.house.GameVersion,
+			&run.timestamp, &run.count)
+
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		runs = append(runs, run)
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, run := range runs {
+		fetchedAt := run.timestamp.Time.Unix()
+
+		snapshot, err := BeginSnapshot(db, run.house, fetchedAt)
+
+		if err != nil {
+			return 0, err
+		}
+
+		snapshot.AuctionCount = run.count
+
+		err = FinishSnapshot(db, snapshot, nil)
+
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = db.Handle.Exec(dialect.Rebind(`UPDATE Auctions SET snapshot_id = ?, last_snapshot_id = ?
+			WHERE snapshot_id IS NULL AND connected_realm_id = ? AND auction_house_id = ? AND timestamp = ?`),
+			snapshot.SnapshotID, snapshot.SnapshotID, run.house.ConnectedRealmID, run.house.AuctionHouseID, dialect.timestamp(fetchedAt))
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(runs), nil
+}
+
+// A row of Auctions, one time_left bucket of an auction
+type bucketRow struct {
+	auctionID      int64
+	snapshotID     int64
+	firstSeen      int64
+	lastSnapshotID int64
+	lastSeen       int64
+	timeLeft       sql.NullString
+	bid            sql.NullInt64
+	buyout         sql.NullInt64
+	unitPrice      sql.NullInt64
+	quantity       sql.NullInt64
+	itemID         sql.NullInt64
+	itemRand       sql.NullInt64
+	itemSeed       sql.NullInt64
+	modifiers      sql.NullString
+	bonusLists     sql.NullString
+	factionID      sql.NullInt64
+	game           GameVersion
+}
+
+// A snapshot of the house being converted
+type houseSnapshot struct {
+	snapshotID int64
+	fetchedAt  int64
+}
+
+func convertHouse(db *Database, connectedRealmID int, auctionHouseID int, summary *ConvertSummary) error {
+	dialect := db.Dialect()
+
+	fetchedAt := map[int64]int64{}
+	complete := []houseSnapshot{}
+	completeAt := map[int64]houseSnapshot{}
+
+	rows, err := db.Handle.Query(dialect.Rebind(`SELECT snapshot_id, fetched_at, status FROM Snapshots
+		WHERE connected_realm_id = ? AND auction_house_id = ?
+		ORDER BY fetched_at, snapshot_id`), connectedRealmID, auctionHouseID)
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var snapshot houseSnapshot
+		var at NullTimestamp
+		var status string
+
+		err = rows.Scan(&snapshot.snapshotID, &at, &status)
+
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		snapshot.fetchedAt = at.Time.Unix()
+		fetchedAt[snapshot.snapshotID] = snapshot.fetchedAt
+
+		if status == SnapshotComplete {
+			complete = append(complete, snapshot)
+			completeAt[snapshot.fetchedAt] = snapshot
+		}
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	tracked, err := trackedAuctions(db, connectedRealmID, auctionHouseID)
+
+	if err != nil {
+		return err
+	}
+
+	// The first complete snapshot after a unix time, false if there is none
+	nextSnapshot := func(after int64) (houseSnapshot, bool) {
+		i := sort.Search(len(complete), func(i int) bool { return complete[i].fetchedAt > after })
+
+		if i == len(complete) {
+			return houseSnapshot{}, false
+		}
+
+		return complete[i], true
+	}
+
+	lastAuctionID := int64(-1)
+
+	for {
+		batch, batchLastID, err := bucketRows(db, connectedRealmID, auctionHouseID, lastAuctionID, fetchedAt)
+
+		if err != nil {
+			return err
+		}
+
+		if batchLastID == lastAuctionID {
+			return nil
+		}
+
+		lastAuctionID = batchLastID
+
+		tx, err := db.Handle.Begin()
+
+		if err != nil {
+			return err
+		}
+
+		lifecycleStmt, err := tx.Prepare(dialect.Upsert("AuctionLifecycles", lifecycleColumns, lifecycleKeyColumns))
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		changeStmt, err := tx.Prepare(dialect.Upsert("AuctionChanges", auctionChangeColumns, auctionChangeKeyColumns))
+
+		if err != nil {
+			lifecycleStmt.Close()
+			tx.Rollback()
+			return err
+		}
+
+		for _, buckets := range batch {
+			endedAt, isTracked := tracked[buckets[0].auctionID]
+
+			if isTracked {
+				// The lifecycle knows which snapshot ended the auction, runs in between may have had delta storage
+				ended, isEnded := completeAt[endedAt.Time.Unix()]
+				err = convertChanges(dialect, changeStmt, connectedRealmID, auctionHouseID, buckets, ended, isEnded && endedAt.Valid, nextSnapshot, summary)
+				summary.Tracked++
+			} else {
+				err = convertAuction(dialect, lifecycleStmt, changeStmt, connectedRealmID, auctionHouseID, buckets, nextSnapshot, summary)
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		changeStmt.Close()
+		lifecycleStmt.Close()
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Writes the lifecycle and the changes of one auction, buckets are its rows of Auctions in the order they were fetched
+func convertAuction(dialect Dialect, lifecycleStmt *sql.Stmt, changeStmt *sql.Stmt, connectedRealmID int, auctionHouseID int,
+	buckets []bucketRow, nextSnapshot func(int64) (houseSnapshot, bool), summary *ConvertSummary) error {
+
+	first := buckets[0]
+	last := buckets[len(buckets)-1]
+
+	var endedAt interface{}
+	var outcome sql.NullString
+	ended, isEnded := nextSnapshot(last.lastSeen)
+
+	if isEnded {
+		endedAt = dialect.timestamp(ended.fetchedAt)
+
+		gap := ended.fetchedAt - last.lastSeen
+		bidOnly := !last.buyout.Valid && !last.unitPrice.Valid
+		outcome = sql.NullString{String: classifyEnding(last.timeLeft.String, time.Duration(gap)*time.Second, bidOnly, false), Valid: true}
+	}
+
+	_, err := lifecycleStmt.Exec(connectedRealmID, last.auctionID, auctionHouseID, last.factionID, last.game,
+		last.itemID, last.itemRand, last.itemSeed, last.modifiers, last.bonusLists,
+		last.quantity, last.bid, last.buyout, last.unitPrice,
+		dialect.timestamp(first.firstSeen), first.snapshotID, first.timeLeft,
+		dialect.timestamp(last.lastSeen), last.lastSnapshotID, last.timeLeft,
+		endedAt, outcome)
+
+	if err != nil {
+		return err
+	}
+
+	err = convertChanges(dialect, changeStmt, connectedRealmID, auctionHouseID, buckets, ended, isEnded, nextSnapshot, summary)
+
+	if err != nil {
+		return err
+	}
+
+	summary.Auctions++
+
+	return nil
+}
+
+// Writes the changes of one auction, the change that ends it only if isEnded
+func convertChanges(dialect Dialect, changeStmt *sql.Stmt, connectedRealmID int, auctionHouseID int,
+	buckets []bucketRow, ended houseSnapshot, isEnded bool, nextSnapshot func(int64) (houseSnapshot, bool), summary *ConvertSummary) error {
+
+	for i, bucket := range buckets {
+		changedAt := bucket.firstSeen
+		snapshotID := bucket.snapshotID
+
+		// The bucket may have started right after the previous one was last seen
+		if i > 0 {
+			if next, ok := nextSnapshot(buckets[i-1].lastSeen); ok && next.fetchedAt < changedAt {
+				changedAt = next.fetchedAt
+				snapshotID = next.snapshotID
+			}
+		}
+
+		_, err := changeStmt.Exec(connectedRealmID, bucket.auctionID, dialect.timestamp(changedAt), auctionHouseID, snapshotID,
+			bucket.timeLeft, bucket.bid)
+
+		if err != nil {
+			return err
+		}
+
+		summary.Changes++
+	}
+
+	if isEnded {
+		_, err := changeStmt.Exec(connectedRealmID, buckets[0].auctionID, dialect.timestamp(ended.fetchedAt), auctionHouseID, ended.snapshotID, nil, nil)
+
+		if err != nil {
+			return err
+		}
+
+		summary.Changes++
+	}
+
+	return nil
+}
+
+// The auctions of the house that already have a lifecycle, and when they ended
+func trackedAuctions(db *Database, connectedRealmID int, auctionHouseID int) (map[int64]NullTimestamp, error) {
+	rows, err := db.Handle.Query(db.Dialect().Rebind(`SELECT auction_id, ended_at FROM AuctionLifecycles
+		WHERE connected_realm_id = ? AND auction_house_id = ?`), connectedRealmID, auctionHouseID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tracked := map[int64]NullTimestamp{}
+
+	for rows.Next() {
+		var auctionID int64
+		var endedAt NullTimestamp
+
+		err = rows.Scan(&auctionID, &endedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tracked[auctionID] = endedAt
+	}
+
+	return tracked, rows.Err()
+}
+
+// Reads the next convertBatchSize rows of the house after auction afterID, grouped by auction and sorted by when they were fetched
+// An auction is never split between batches. Rows of snapshots that no longer exist are left out.
+// Also returns the last auction that was read, afterID once every row has been read.
+func bucketRows(db *Database, connectedRealmID int, auctionHouseID int, afterID int64, fetchedAt map[int64]int64) ([][]bucketRow, int64, error) {
+	rows, err := db.Handle.Query(db.Dialect().Rebind(`SELECT auction_id, snapshot_id, COALESCE(last_snapshot_id, snapshot_id), time_left, bid, buyout, unit_price, quantity,
+			item_id, item_rand, item_seed, item_modifiers, item_bonus_lists, faction_id, game_version
+		FROM Auctions
+		WHERE connected_realm_id = ? AND auction_house_id = ? AND snapshot_id IS NOT NULL AND auction_id > ?
+		ORDER BY auction_id
+		LIMIT ?`), connectedRealmID, auctionHouseID, afterID, convertBatchSize)
+
+	if err != nil {
+		return nil, afterID, err
+	}
+
+	defer rows.Close()
+
+	auctions := [][]bucketRow{}
+	count := 0
+
+	for rows.Next() {
+		var row bucketRow
+
+		err = rows.Scan(&row.auctionID, &row.snapshotID, &row.lastSnapshotID, &row.timeLeft, &row.bid, &row.buyout, &row.unitPrice, &row.quantity,
+			&row.itemID, &row.itemRand, &row.itemSeed, &row.modifiers, &row.bonusLists, &row.factionID, &row.game)
+
+		if err != nil {
+			return nil, afterID, err
+		}
+
+		count++
+
+		if len(auctions) == 0 || auctions[len(auctions)-1][0].auctionID != row.auctionID {
+			auctions = append(auctions, []bucketRow{})
+		}
+
+		auctions[len(auctions)-1] = append(auctions[len(auctions)-1], row)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, afterID, err
+	}
+
+	// The last auction may have more rows in the next batch
+	if count == convertBatchSize && len(auctions) > 1 {
+		auctions = auctions[:len(auctions)-1]
+	}
+
+	lastID := afterID
+	if len(auctions) > 0 {
+		lastID = auctions[len(auctions)-1][0].auctionID
+	}
+
+	converted := auctions[:0]
+
+	for _, buckets := range auctions {
+		known := buckets[:0]
+
+		for _, bucket := range buckets {
+			at, ok := fetchedAt[bucket.lastSnapshotID]
+
+			if !ok {
+				continue
+			}
+
+			bucket.lastSeen = at
+			bucket.firstSeen = at
+
+			if first, ok := fetchedAt[bucket.snapshotID]; ok {
+				bucket.firstSeen = first
+			} else {
+				bucket.snapshotID = bucket.lastSnapshotID
+			}
+
+			known = append(known, bucket)
+		}
+
+		if len(known) == 0 {
+			continue
+		}
+
+		sort.Slice(known, func(i, j int) bool { return known[i].lastSeen < known[j].lastSeen })
+		converted = append(converted, known)
+	}
+
+	return converted, lastID, nil
+}
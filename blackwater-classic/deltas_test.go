@@ -0,0 +1,227 @@
+package blackwater
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseAuctionStorage(t *testing.T) {
+	for _, storage := range []AuctionStorage{FullStorage, DeltaStorage} {
+		parsed, err := ParseAuctionStorage(storage.String())
+
+		if err != nil || parsed != storage {
+			t.Errorf("%s: got %v, %v", storage, parsed, err)
+		}
+	}
+
+	if _, err := ParseAuctionStorage("partial"); err == nil {
+		t.Error("expected an error for an unknown storage")
+	}
+}
+
+func TestHouseAt(t *testing.T) {
+	start := int64(1700000000)
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+
+	tests := []struct {
+		name        string
+		storages    []AuctionStorage // Of each dump
+		auctionRows int
+		changes     int
+	}{
+		{"full", []AuctionStorage{FullStorage, FullStorage, FullStorage}, 7, 0},
+		// 6 appearances, 5 endings, and auction 5 changed its time left and bid once
+		{"delta", []AuctionStorage{DeltaStorage, DeltaStorage, DeltaStorage}, 0, 12},
+		// The delta snapshot lists 5 and 10 and ends 1 to 4
+		{"full then delta", []AuctionStorage{FullStorage, DeltaStorage, FullStorage}, 6, 6},
+		// The delta snapshots list 1 to 5, then 10 and end 5
+		{"delta then full", []AuctionStorage{DeltaStorage, FullStorage, DeltaStorage}, 2, 7},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+
+			for i, dump := range lifecycleTestDumps {
+				database.AuctionStorage = test.storages[i]
+				importTestDumps(t, database, house, start+int64(i)*3600, []string{dump})
+			}
+
+			for i, dump := range lifecycleTestDumps {
+				// Half an hour after the snapshot is still the snapshot
+				snapshot, auctions, err := HouseAt(database, 5284, 2, start+int64(i)*3600+1800)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if snapshot.FetchedAt != start+int64(i)*3600 || snapshot.AuctionCount != len(auctions) {
+					t.Errorf("snapshot %d: unexpected %+v", i, snapshot)
+				}
+
+				var expected AuctionJson
+				if err = json.Unmarshal([]byte(dump), &expected); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(auctions, expected.Auctions) {
+					t.Errorf("snapshot %d: expected %+v, rebuilt %+v", i, expected.Auctions, auctions)
+				}
+			}
+
+			if _, _, err := HouseAt(database, 5284, 2, start-1); !errors.Is(err, ErrNoSnapshot) {
+				t.Errorf("expected ErrNoSnapshot before the first snapshot, got %v", err)
+			}
+
+			if n := countRows(t, database, "Auctions"); n != test.auctionRows {
+				t.Errorf("expected %d rows in Auctions, got %d", test.auctionRows, n)
+			}
+
+			if n := countRows(t, database, "PendingAuctions"); n != 0 {
+				t.Errorf("expected no pending auctions, got %d", n)
+			}
+
+			if n := countRows(t, database, "AuctionChanges"); n != test.changes {
+				t.Errorf("expected %d changes, got %d", test.changes, n)
+			}
+		})
+	}
+}
+
+func TestConvertAuctions(t *testing.T) {
+	database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+	start := int64(1700000000)
+
+	importTestDumps(t, database, house, start, lifecycleTestDumps)
+
+	// A database from before snapshots and lifecycles were recorded
+	for _, query := range []string{
+		`DELETE FROM AuctionChanges`,
+		`DELETE FROM AuctionLifecycles`,
+		`DELETE FROM Snapshots`,
+		`UPDATE Auctions SET snapshot_id = NULL, last_snapshot_id = NULL`,
+	} {
+		if _, err := database.Handle.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := ConvertAuctions(database, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ConvertSummary{Houses: 1, Snapshots: 3, Auctions: 6, Changes: 12, Deleted: 7}
+	if summary != expected {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+
+	if n := countRows(t, database, "Auctions"); n != 0 {
+		t.Errorf("expected the converted rows to be deleted, %d are left", n)
+	}
+
+	// Auctions only kept when 10 was last seen, so it is missing from the second snapshot
+	expectedIDs := [][]int{{1, 2, 3, 4, 5}, {5}, {10}}
+
+	for i, ids := range expectedIDs {
+		_, auctions, err := HouseAt(database, 5284, 2, start+int64(i)*3600)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rebuilt := []int{}
+		for _, auction := range auctions {
+			rebuilt = append(rebuilt, auction.ID)
+		}
+
+		if !reflect.DeepEqual(rebuilt, ids) {
+			t.Errorf("snapshot %d: expected auctions %v, rebuilt %v", i, ids, rebuilt)
+		}
+	}
+
+	_, auctions, err := HouseAt(database, 5284, 2, start+3600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(auctions) != 1 || auctions[0].Bid != 150 || auctions[0].TimeLeft != "LONG" {
+		t.Errorf("expected auction 5 as it was in the second snapshot, got %+v", auctions)
+	}
+
+	// Converting again finds nothing left to do
+	summary, err = ConvertAuctions(database, true)
+
+	if err != nil || summary != (ConvertSummary{}) {
+		t.Errorf("expected nothing to convert, got %+v, %v", summary, err)
+	}
+}
+
+// Full storage tracks the lifecycles, converting only adds the changes
+func TestConvertTrackedAuctions(t *testing.T) {
+	database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+	start := int64(1700000000)
+
+	importTestDumps(t, database, house, start, lifecycleTestDumps)
+
+	var outcome string
+	if err := database.Handle.QueryRow(`SELECT outcome FROM AuctionLifecycles WHERE auction_id = 4`).Scan(&outcome); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := ConvertAuctions(database, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ConvertSummary{Houses: 1, Tracked: 6, Changes: 12, Deleted: 7}
+	if summary != expected {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+
+	// The lifecycle knew that 4 was listed again
+	var converted string
+	if err = database.Handle.QueryRow(`SELECT outcome FROM AuctionLifecycles WHERE auction_id = 4`).Scan(&converted); err != nil {
+		t.Fatal(err)
+	}
+
+	if converted != outcome || outcome != OutcomeCancelled {
+		t.Errorf("expected auction 4 to stay %s, got %s", outcome, converted)
+	}
+
+	// Without the rows of Auctions the changes rebuild the same dumps
+	for i, dump := range lifecycleTestDumps {
+		_, auctions, err := HouseAt(database, 5284, 2, start+int64(i)*3600)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expected AuctionJson
+		if err = json.Unmarshal([]byte(dump), &expected); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(auctions, expected.Auctions) {
+			t.Errorf("snapshot %d: expected %+v, rebuilt %+v", i, expected.Auctions, auctions)
+		}
+	}
+}
+
+func countRows(t *testing.T, database *Database, table string) int {
+	t.Helper()
+
+	var n int
+	if err := database.Handle.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
@@ -0,0 +1,152 @@
+package blackwater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The SQL flavour of a database, it decides the syntax of the statements we generate
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	MySQL
+	Postgres
+)
+
+// Indexed by Dialect, these are also the database_type values in db.json
+var DialectStrings = []string{"sqlite3", "mysql", "postgres"}
+
+func (d Dialect) String() string {
+	if d < 0 || int(d) >= len(DialectStrings) {
+		return fmt.Sprintf("Dialect(%d)", int(d))
+	}
+
+	return DialectStrings[d]
+}
+
+// Parses the database_type of db.json, which is also the name of the database/sql driver
+func ParseDialect(databaseType string) (Dialect, error) {
+	for i, name := range DialectStrings {
+		if strings.EqualFold(name, databaseType) {
+			return Dialect(i), nil
+		}
+	}
+
+	return SQLite, fmt.Errorf("unknown database type %q", databaseType)
+}
+
+// Rewrites the ? placeholders of query to the $1, $2, ... placeholders of Postgres
+// Queries for the other dialects are returned as they are.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Timestamps are unix times, Postgres stores them as TIMESTAMPTZ
+func (d Dialect) timestamp(unix int64) interface{} {
+	if d == Postgres {
+		return time.Unix(unix, 0).UTC()
+	}
+
+	return unix
+}
+
+// A timestamp column that may be NULL
+// Scans the unix times of MySQL, the DATETIME columns of SQLite and the TIMESTAMPTZ columns of Postgres.
+type NullTimestamp struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *NullTimestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = NullTimestamp{}
+	case int64:
+		*t = NullTimestamp{Time: time.Unix(v, 0).UTC(), Valid: true}
+	case time.Time:
+		*t = NullTimestamp{Time: v.UTC(), Valid: true}
+	case []byte:
+		unix, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("can't scan %q as a timestamp", v)
+		}
+
+		*t = NullTimestamp{Time: time.Unix(unix, 0).UTC(), Valid: true}
+	default:
+		return fmt.Errorf("can't scan %T as a timestamp", value)
+	}
+
+	return nil
+}
+
+// Returns a statement that inserts a row or updates it if a row with the same keys exists
+// Unlike INSERT OR REPLACE the existing row is updated in place, it is not deleted first.
+// If every column is a key an existing row is left as it is.
+func (d Dialect) Upsert(table string, columns []string, keys []string) string {
+	return d.UpsertUpdating(table, columns, columns, keys)
+}
+
+// Same as Upsert, but only the columns in updates are changed when the row exists
+func (d Dialect) UpsertUpdating(table string, columns []string, updates []string, keys []string) string {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = "?"
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	return d.Rebind(insert) + d.onConflict(updates, keys)
+}
+
+// The clause of an upsert that updates the columns that are not keys
+func (d Dialect) onConflict(columns []string, keys []string) string {
+	updates := []string{}
+	for _, column := range columns {
+		if containsFold(keys, column) {
+			continue
+		}
+
+		if d == MySQL {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+
+	if d == MySQL {
+		if len(updates) == 0 {
+			// MySQL has no DO NOTHING, assigning a key to itself changes nothing
+			updates = append(updates, fmt.Sprintf("%s = %s", keys[0], keys[0]))
+		}
+
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+
+	conflict := fmt.Sprintf(" ON CONFLICT (%s) DO ", strings.Join(keys, ", "))
+
+	if len(updates) == 0 {
+		return conflict + "NOTHING"
+	}
+
+	return conflict + "UPDATE SET " + strings.Join(updates, ", ")
+}
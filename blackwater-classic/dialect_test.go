@@ -0,0 +1,58 @@
+package blackwater
+
+import "testing"
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		keys     []string
+		expected string
+	}{
+		{SQLite, []string{"id"},
+			"INSERT INTO Realms (id, name) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET name = excluded.name"},
+		{SQLite, []string{"id", "name"},
+			"INSERT INTO Realms (id, name) VALUES (?, ?) ON CONFLICT (id, name) DO NOTHING"},
+		{MySQL, []string{"id"},
+			"INSERT INTO Realms (id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)"},
+		{MySQL, []string{"id", "name"},
+			"INSERT INTO Realms (id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = id"},
+		{Postgres, []string{"id"},
+			"INSERT INTO Realms (id, name) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET name = excluded.name"},
+	}
+
+	for _, test := range tests {
+		if got := test.dialect.Upsert("Realms", []string{"id", "name"}, test.keys); got != test.expected {
+			t.Errorf("%s %v:\ngot      %s\nexpected %s", test.dialect, test.keys, got, test.expected)
+		}
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	for _, name := range []string{"sqlite3", "mysql", "MySQL", "postgres"} {
+		dialect, err := ParseDialect(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dialect.String() != DialectStrings[dialect] {
+			t.Errorf("%s parsed as %s", name, dialect)
+		}
+	}
+
+	if _, err := ParseDialect("oracle"); err == nil {
+		t.Error("expected an error for an unknown database type")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT name FROM Realms WHERE realm_id = ? AND game_version = ?`
+
+	if got := SQLite.Rebind(query); got != query {
+		t.Errorf("SQLite: %s", got)
+	}
+
+	expected := `SELECT name FROM Realms WHERE realm_id = $1 AND game_version = $2`
+	if got := Postgres.Rebind(query); got != expected {
+		t.Errorf("Postgres: %s", got)
+	}
+}
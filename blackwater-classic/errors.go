@@ -0,0 +1,125 @@
+package blackwater
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Returned when a href from a previous response is missing
+var ErrEmptyHref = errors.New("href is empty")
+
+// Returned when a conditional request is answered with 304 Not Modified
+var ErrNotModified = errors.New("not modified")
+
+// Returned by HouseAt when the house has no complete snapshot at that time
+var ErrNoSnapshot = errors.New("no complete snapshot")
+
+// Returned by MigrateUp when a database from before migrations can't be converted
+var ErrLegacySchema = errors.New("the database has the schema from before migrations")
+
+// Returned when a response could not be decompressed or decoded
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("could not decode the response: %v", e.Err)
+	}
+
+	return fmt.Sprintf("could not decode the response from %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Error body returned by the Blizzard API, e.g.
+// {"code": 404, "type": "BLZWEBAPI00000404", "detail": "Not Found"}
+type BlizzardErrorJson struct {
+	Code   int    `json:"code"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+// Returned when the API answers with anything but 200 OK
+type APIError struct {
+	StatusCode int
+	Endpoint   string // path of the request, e.g. data/wow/connected-realm/5284
+	Namespace  string
+	Body       BlizzardErrorJson // empty if the body was not a Blizzard error
+	RetryAfter time.Duration     // from the Retry-After header, if any
+}
+
+func newAPIError(u string, res *fasthttp.Response) *APIError {
+	apiError := &APIError{
+		StatusCode: res.StatusCode(),
+		Endpoint:   u,
+		RetryAfter: parseRetryAfter(string(res.Header.Peek("Retry-After"))),
+	}
+
+	if parsedURL, err := url.Parse(u); err == nil {
+		apiError.Endpoint = strings.TrimPrefix(parsedURL.Path, "/")
+		apiError.Namespace = parsedURL.Query().Get("namespace")
+	}
+
+	body := res.Body()
+
+	if bytes.EqualFold(res.Header.ContentEncoding(), []byte("gzip")) {
+		if unzipped, err := res.BodyGunzip(); err == nil {
+			body = unzipped
+		}
+	}
+
+	// Not every error comes with a JSON body, e.g. errors from a proxy
+	json.Unmarshal(body, &apiError.Body)
+
+	return apiError
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("%s (namespace %s) returned status %d", e.Endpoint, e.Namespace, e.StatusCode)
+
+	if e.Body.Detail != "" {
+		message += fmt.Sprintf(": %s (%s)", e.Body.Detail, e.Body.Type)
+	}
+
+	return message
+}
+
+// 429 and 5xx are worth trying again later
+func (e *APIError) Retryable() bool {
+	return retryableStatus(e.StatusCode)
+}
+
+// The realm, auction house or item does not exist (anymore)
+func IsNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == fasthttp.StatusNotFound
+}
+
+// We are sending requests too fast
+func IsRateLimited(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == fasthttp.StatusTooManyRequests
+}
+
+// The token was rejected
+func IsUnauthorized(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == fasthttp.StatusUnauthorized
+}
+
+// The request might succeed if it is sent again later
+func IsRetryable(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.Retryable()
+}
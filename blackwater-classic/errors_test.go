@@ -0,0 +1,62 @@
+package blackwater
+
+import (
+	"blackwater/blackwater-classic/blackwatertest"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPIErrorNotFound(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{})
+
+	_, err := api.ConnectedRealm(1)
+
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+
+	if apiError.StatusCode != 404 || apiError.Endpoint != "data/wow/connected-realm/1" || apiError.Namespace != "dynamic-classic1x-eu" {
+		t.Errorf("unexpected error fields: %+v", apiError)
+	}
+
+	if apiError.Body.Code != 404 || apiError.Body.Type != "BLZWEBAPI00000404" || apiError.Body.Detail != "Not Found" {
+		t.Errorf("the Blizzard error body was not decoded: %+v", apiError.Body)
+	}
+
+	if !IsNotFound(err) || IsRateLimited(err) || IsRetryable(err) {
+		t.Errorf("wrong classification of %v", err)
+	}
+}
+
+func TestAPIErrorRetryable(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{Retry: &RetryPolicy{MaxAttempts: 1}})
+
+	// Compressed endpoints report errors the same way
+	server.FailNext("/data/wow/connected-realm/5284/auctions/2", http.StatusTooManyRequests)
+
+	_, err := api.ClassicAuctions(5284, 2)
+
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+
+	if !IsRateLimited(err) || !IsRetryable(err) || IsNotFound(err) || apiError.RetryAfter != time.Second {
+		t.Errorf("wrong classification of %+v", apiError)
+	}
+
+	server.FailNext("/data/wow/item/13452", http.StatusServiceUnavailable)
+
+	if _, err = api.ClassicItem(13452); !IsRetryable(err) || IsRateLimited(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
@@ -1,32 +1,47 @@
 package blackwater
 
 import (
-	"database/sql"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"time"
-
-	"github.com/valyala/fasthttp"
 )
 
-const itemPrepareStatement = `INSERT OR REPLACE INTO Items(
-	item_id, 
-	item_class_id, item_class, 
-	item_subclass_id, item_subclass,
-	quality, name) VALUES(?, ?, ?, ?, ?, ?, ?)`
+var itemInsertColumns = []string{
+	"item_id", "game_version",
+	"item_class_id", "item_class",
+	"item_subclass_id", "item_subclass",
+	"quality", "name", "locale",
+}
+
+// Caches the names and classes of the items in the auctions that are not cached yet for the game version of api
+// Names are stored in the locale of api. An item is only fetched once, so the names stay in the first locale they were cached in.
+func CacheItems(api *API, db *Database) error {
+
+	insertItem := db.Dialect().Upsert("Items", itemInsertColumns, []string{"item_id", "game_version"})
 
-func CacheItems(api *API, db *sql.DB) error {
+	// Items are cached separately for every game version
+	// Auctions kept with delta storage are only in AuctionLifecycles
+	sources := `SELECT item_id FROM Auctions WHERE game_version = ?
+		UNION
+		SELECT item_id FROM AuctionLifecycles WHERE game_version = ?`
 
-	rowsQuery, err := db.Query(`SELECT DISTINCT A.item_id
-	FROM Auctions A
+	// Commodities are Retail items
+	if api.gameVersion == Retail {
+		sources += `
+		UNION
+		SELECT item_id FROM Commodities`
+	}
+
+	rowsQuery, err := db.Handle.Query(db.Dialect().Rebind(fmt.Sprintf(`SELECT DISTINCT A.item_id
+	FROM (%s) A
 	LEFT JOIN Items I
-	ON A.item_id = I.item_id
+	ON A.item_id = I.item_id AND I.game_version = ?
 	WHERE I.item_id IS NULL
-	ORDER BY A.item_id;`)
+	ORDER BY A.item_id;`, sources)), api.gameVersion, api.gameVersion, api.gameVersion)
 
 	if err != nil {
 		log.Printf("Cannot cache items: %q\n", err)
+		return err
 	}
 
 	itemIDs := []int{}
@@ -39,7 +54,7 @@ func CacheItems(api *API, db *sql.DB) error {
 		err = rowsQuery.Scan(&itemID)
 
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		itemIDs = append(itemIDs, itemID)
@@ -48,18 +63,21 @@ func CacheItems(api *API, db *sql.DB) error {
 	err = rowsQuery.Err()
 
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx, err := db.Begin()
+	rowsQuery.Close()
+
+	tx, err := db.Handle.Begin()
 
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare(itemPrepareStatement)
+	stmt, err := tx.Prepare(insertItem)
 
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
@@ -71,44 +89,41 @@ func CacheItems(api *API, db *sql.DB) error {
 
 	for _, itemID := range itemIDs {
 		if failedCounter > 5 {
-			return errors.New("can't call the blizzard api at the moment")
-		}
-		res, err := api.ClassicItem(itemID)
-
-		if err != nil {
-			log.Printf("CacheItems tried to call ClassicItem(%d) but did not receive an accepted HTTP answer.\n", itemID)
-			log.Println("Re-trying again after 10 seconds...")
-			time.Sleep(10 * time.Second)
-
-			log.Printf("Attempting to call ClassicItem(%d) again\n", itemID)
-			res, err = api.ClassicItem(itemID)
+			// The items fetched so far are kept
+			err := tx.Commit()
 
 			if err != nil {
-				log.Println("CacheItems failed again...")
-				failedCounter++
-				continue
+				log.Println("Could not commit")
+				return err
 			}
-		}
 
-		defer fasthttp.ReleaseResponse(res)
+			return errors.New("can't call the blizzard api at the moment")
+		}
+		// The API client already retries and throttles the request
+		itemJson, err := api.ClassicItem(itemID)
 
-		var itemJson ItemJson
-		err = json.Unmarshal(res.Body(), &itemJson)
+		if IsNotFound(err) {
+			// Nothing we can do about items that the API doesn't know about
+			log.Printf("Item %d does not exist in the item API, skipping it\n", itemID)
+			continue
+		}
 
 		if err != nil {
-			return err
+			log.Printf("CacheItems tried to call ClassicItem(%d) but did not receive an accepted HTTP answer: %q\n", itemID, err)
+			failedCounter++
+			continue
 		}
 
 		_, err = stmt.Exec(
-			itemID,
+			itemID, api.gameVersion,
 			itemJson.ItemClass.ID, itemJson.ItemClass.Name,
 			itemJson.ItemSubClass.ID, itemJson.ItemSubClass.Name,
 			itemJson.Quality.Name,
-			itemJson.Name)
+			itemJson.Name, api.locale)
 
 		if err != nil {
 			tx.Rollback()
-			return nil
+			return err
 		}
 
 		counter++
@@ -120,15 +135,16 @@ func CacheItems(api *API, db *sql.DB) error {
 				log.Println("Could not commit")
 				return err
 			}
-			tx, err = db.Begin()
+			tx, err = db.Handle.Begin()
 			if err != nil {
 				log.Println("Could not begin")
 				return err
 			}
 
-			stmt, err = tx.Prepare(itemPrepareStatement)
+			stmt, err = tx.Prepare(insertItem)
 
 			if err != nil {
+				tx.Rollback()
 				return err
 			}
 
@@ -136,13 +152,11 @@ func CacheItems(api *API, db *sql.DB) error {
 
 		}
 
-		time.Sleep(500 * time.Millisecond)
-
 	}
 	err = tx.Commit()
 
 	if err != nil {
-		return nil
+		return err
 	}
 
 	log.Println("Finished caching items.")
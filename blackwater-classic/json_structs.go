@@ -1,5 +1,53 @@
 package blackwater
 
+import (
+	"encoding/json"
+	"sort"
+)
+
+// A name that is either a plain string, when a locale was requested,
+// or an object with one string per locale, e.g. {"en_GB": "Horde Auction House", "de_DE": "..."}
+type LocalizedString map[Locale]string
+
+func (ls *LocalizedString) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err == nil {
+		*ls = LocalizedString{"": s}
+		return nil
+	}
+
+	var m map[Locale]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	*ls = m
+	return nil
+}
+
+// Returns the string in locale, falling back to English or any locale
+func (ls LocalizedString) In(locale Locale) string {
+	for _, l := range []Locale{locale, "", EnGB, EnUS} {
+		if s, ok := ls[l]; ok {
+			return s
+		}
+	}
+
+	locales := make([]string, 0, len(ls))
+	for l := range ls {
+		locales = append(locales, string(l))
+	}
+
+	if len(locales) == 0 {
+		return ""
+	}
+
+	sort.Strings(locales)
+
+	return ls[Locale(locales[0])]
+}
+
 type DatabaseJson struct {
 	DatabaseType     string `json:"database_type"`
 	ConnectionString string `json:"connection_string"`
@@ -12,21 +60,38 @@ type ConnectedRealmsIndexJson struct {
 }
 
 type ConnectedRealmJson struct {
-	ID     int `json:"id"`
-	Realms []struct {
-		ID           int    `json:"id"`
-		Name         string `json:"name"`
-		Timezone     string `json:"timezone"`
-		IsTournament bool   `json:"is_tournament"`
-	} `json:"realms"`
-}
+	ID       int  `json:"id"`
+	HasQueue bool `json:"has_queue"`
+
+	Status struct {
+		Type string `json:"type"`
+	} `json:"status"`
+
+	Population struct {
+		Type string `json:"type"`
+	} `json:"population"`
+
+	Realms []RealmJson `json:"realms"`
 
-type ConnectedRealmJsonLite struct {
 	Auctions struct {
 		Href string `json:"href"`
 	} `json:"auctions"`
 }
 
+// One of the realms of a connected realm
+type RealmJson struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	Category string `json:"category"`
+	Type     struct {
+		Type string `json:"type"`
+	} `json:"type"`
+	IsTournament bool `json:"is_tournament"`
+}
+
 type ConnectedRealmSearchJson struct {
 	Results []struct {
 		Data struct {
@@ -41,9 +106,7 @@ type AuctionHouseMetaDataJson struct {
 			Href string `json:"href"`
 		} `json:"key"`
 
-		Name struct {
-			En_GB string `json:"en_GB"`
-		} `json:"name"`
+		Name LocalizedString `json:"name"`
 
 		ID int `json:"id"`
 	} `json:"auctions"`
@@ -51,14 +114,46 @@ type AuctionHouseMetaDataJson struct {
 
 // An auction that you can find in the auction house
 type AuctionJson struct {
+	Auctions []AuctionListingJson `json:"auctions"`
+}
+
+// One auction of an auction house dump
+// Bid-only auctions have no buyout, stackable items on Retail have a unit price instead of a buyout.
+type AuctionListingJson struct {
+	ID        int             `json:"id"`
+	Item      AuctionItemJson `json:"item"`
+	Bid       int             `json:"bid"`
+	Buyout    int             `json:"buyout"`
+	UnitPrice int             `json:"unit_price"`
+	Quantity  int             `json:"quantity"`
+	TimeLeft  string          `json:"time_left"`
+}
+
+// The item of an auction
+// Rand is the random suffix of Classic items, e.g. "of the Monkey", seed tells the rolls of the item apart.
+// Retail items are told apart by their bonus lists and modifiers instead.
+type AuctionItemJson struct {
+	ID        int   `json:"id"`
+	Rand      int   `json:"rand"`
+	Seed      int64 `json:"seed"`
+	Modifiers []struct {
+		Type  int `json:"type"`
+		Value int `json:"value"`
+	} `json:"modifiers"`
+	BonusLists []int `json:"bonus_lists"`
+}
+
+// Region wide commodity market on Retail, e.g. herbs, ore and potions
+// Commodities are sold per unit and have no buyout or bid
+type CommoditiesJson struct {
 	Auctions []struct {
 		ID   int `json:"id"`
 		Item struct {
 			ID int `json:"id"`
 		} `json:"item"`
-		Buyout   int    `json:"buyout"`
-		Quantity int    `json:"quantity"`
-		TimeLeft string `json:"time_left"`
+		Quantity  int    `json:"quantity"`
+		UnitPrice int    `json:"unit_price"`
+		TimeLeft  string `json:"time_left"`
 	} `json:"auctions"`
 }
 
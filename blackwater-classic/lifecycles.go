@@ -0,0 +1,290 @@
+package blackwater
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How an auction most likely ended, see classifyEnding
+const (
+	OutcomeSold      = "sold"
+	OutcomeExpired   = "expired"
+	OutcomeCancelled = "cancelled"
+)
+
+// The least and the most time an auction may have left in each time_left bucket
+var timeLeftBuckets = map[string][2]time.Duration{
+	"SHORT":     {0, 30 * time.Minute},
+	"MEDIUM":    {30 * time.Minute, 2 * time.Hour},
+	"LONG":      {2 * time.Hour, 12 * time.Hour},
+	"VERY_LONG": {12 * time.Hour, 48 * time.Hour},
+}
+
+var lifecycleColumns = []string{
+	"connected_realm_id", "auction_id", "auction_house_id", "faction_id", "game_version",
+	"item_id", "item_rand", "item_seed", "item_modifiers", "item_bonus_lists",
+	"quantity", "bid", "buyout", "unit_price",
+	"first_seen", "first_snapshot_id", "first_time_left",
+	"last_seen", "last_snapshot_id", "last_time_left",
+	"ended_at", "outcome",
+}
+
+var lifecycleKeyColumns = []string{"connected_realm_id", "auction_id"}
+
+// Only these change when an auction is seen again, an auction that comes back is listed again
+var lifecycleUpdateColumns = []string{"bid", "last_seen", "last_snapshot_id", "last_time_left", "ended_at", "outcome"}
+
+// An auction that was listed in the previous complete snapshot of its house but is missing from this one
+type endedAuction struct {
+	auctionID    int64
+	lastSeen     NullTimestamp
+	lastTimeLeft sql.NullString
+	listing      relistKey
+	bidOnly      bool
+}
+
+// Auctions of the same stack of the same item
+type relistKey struct {
+	itemID   sql.NullInt64
+	itemRand sql.NullInt64
+	quantity sql.NullInt64
+}
+
+// Compares a complete snapshot with the previous complete snapshot of its house
+// Auctions of the snapshot are added to AuctionLifecycles or have their last_seen moved forward,
+// auctions that are no longer listed get an ended_at and the outcome classifyEnding guesses.
+// With delta storage every auction that appeared, changed or ended is also recorded in AuctionChanges,
+// full storage has them in Auctions already.
+// The auctions of the snapshot must already be stored, they are read back from Auctions,
+// or PendingAuctions for delta storage, by their last_snapshot_id.
+func TrackLifecycles(db *Database, snapshot *Snapshot) error {
+	dialect := db.Dialect()
+
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	table := db.auctionTable()
+	delta := db.AuctionStorage == DeltaStorage
+
+	if delta {
+		err = insertChanges(tx, dialect, snapshot)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Bids go up while an auction is listed, the rest of it stays the same
+	_, err = tx.Exec(dialect.Rebind(fmt.Sprintf(`INSERT INTO AuctionLifecycles (%s)
+		SELECT connected_realm_id, auction_id, auction_house_id, faction_id, game_version,
+			item_id, item_rand, item_seed, item_modifiers, item_bonus_lists,
+			quantity, bid, buyout, unit_price,
+			timestamp, last_snapshot_id, time_left,
+			timestamp, last_snapshot_id, time_left,
+			NULL, NULL
+		FROM %s WHERE last_snapshot_id = ?`, strings.Join(lifecycleColumns, ", "), table))+
+		dialect.onConflict(lifecycleUpdateColumns, lifecycleKeyColumns), snapshot.SnapshotID)
+
+	if err != nil {
+		return err
+	}
+
+	ended, err := endedAuctions(tx, dialect, snapshot)
+
+	if err != nil {
+		return err
+	}
+
+	err = endAuctions(tx, dialect, snapshot, ended, delta)
+
+	if err != nil {
+		return err
+	}
+
+	if delta {
+		// Rows of a snapshot that failed halfway are removed as well
+		_, err = tx.Exec(dialect.Rebind(`DELETE FROM PendingAuctions WHERE connected_realm_id = ? AND auction_house_id = ?`),
+			snapshot.ConnectedRealmID, snapshot.AuctionHouseID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Records the auctions of a delta snapshot that are new or differ from their latest change
+// Compared with the changes rather than the lifecycles, which full snapshots move on without a change,
+// so an auction that was last seen by a full snapshot gets a change as well.
+// The join is wrapped so that the columns of the upsert are not ambiguous for MySQL.
+func insertChanges(tx *sql.Tx, dialect Dialect, snapshot *Snapshot) error {
+	_, err := tx.Exec(dialect.Rebind(fmt.Sprintf(`INSERT INTO AuctionChanges (%s)
+		SELECT * FROM (
+			SELECT A.connected_realm_id, A.auction_id, A.timestamp, A.auction_house_id, A.last_snapshot_id, A.time_left, A.bid
+			FROM PendingAuctions A
+			LEFT JOIN AuctionChanges C ON C.connected_realm_id = A.connected_realm_id AND C.auction_id = A.auction_id
+				AND C.changed_at = (SELECT MAX(C2.changed_at) FROM AuctionChanges C2
+					WHERE C2.connected_realm_id = A.connected_realm_id AND C2.auction_id = A.auction_id)
+			WHERE A.last_snapshot_id = ?
+			AND (C.auction_id IS NULL OR C.time_left IS NULL
+				OR COALESCE(C.time_left, '') <> COALESCE(A.time_left, '') OR COALESCE(C.bid, 0) <> COALESCE(A.bid, 0))
+		) AS changes WHERE TRUE`,
+		strings.Join(auctionChangeColumns, ", ")))+
+		dialect.onConflict(auctionChangeColumns, auctionChangeKeyColumns), snapshot.SnapshotID)
+
+	return err
+}
+
+// Records how the auctions that are missing from the snapshot ended, and with delta storage the changes that end them
+func endAuctions(tx *sql.Tx, dialect Dialect, snapshot *Snapshot, ended []endedAuction, delta bool) error {
+	if len(ended) == 0 {
+		return nil
+	}
+
+	relisted, err := newListings(tx, dialect, snapshot)
+
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(dialect.Rebind(`UPDATE AuctionLifecycles SET ended_at = ?, outcome = ?
+		WHERE connected_realm_id = ? AND auction_id = ?`))
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	var changeStmt *sql.Stmt
+
+	if delta {
+		changeStmt, err = tx.Prepare(dialect.Upsert("AuctionChanges", auctionChangeColumns, auctionChangeKeyColumns))
+
+		if err != nil {
+			return err
+		}
+
+		defer changeStmt.Close()
+	}
+
+	endedAt := dialect.timestamp(snapshot.FetchedAt)
+
+	for _, auction := range ended {
+		gap := time.Duration(snapshot.FetchedAt-auction.lastSeen.Time.Unix()) * time.Second
+
+		// A new auction of the same stack takes the place of at most one that ended
+		isRelisted := relisted[auction.listing] > 0
+		if isRelisted {
+			relisted[auction.listing]--
+		}
+
+		outcome := classifyEnding(auction.lastTimeLeft.String, gap, auction.bidOnly, isRelisted)
+
+		_, err = stmt.Exec(endedAt, outcome, snapshot.ConnectedRealmID, auction.auctionID)
+
+		if err != nil {
+			return err
+		}
+
+		if !delta {
+			continue
+		}
+
+		_, err = changeStmt.Exec(snapshot.ConnectedRealmID, auction.auctionID, endedAt, snapshot.AuctionHouseID, snapshot.SnapshotID, nil, nil)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The auctions of the house that are still listed but were not seen in the snapshot
+func endedAuctions(tx *sql.Tx, dialect Dialect, snapshot *Snapshot) ([]endedAuction, error) {
+	rows, err := tx.Query(dialect.Rebind(`SELECT auction_id, last_seen, last_time_left, item_id, item_rand, quantity, buyout IS NULL AND unit_price IS NULL
+		FROM AuctionLifecycles
+		WHERE connected_realm_id = ? AND auction_house_id = ? AND ended_at IS NULL AND last_snapshot_id <> ?`),
+		snapshot.ConnectedRealmID, snapshot.AuctionHouseID, snapshot.SnapshotID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ended := []endedAuction{}
+
+	for rows.Next() {
+		var auction endedAuction
+
+		err = rows.Scan(&auction.auctionID, &auction.lastSeen, &auction.lastTimeLeft,
+			&auction.listing.itemID, &auction.listing.itemRand, &auction.listing.quantity, &auction.bidOnly)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ended = append(ended, auction)
+	}
+
+	return ended, rows.Err()
+}
+
+// Counts the auctions the snapshot is the first to list, by item and stack size
+func newListings(tx *sql.Tx, dialect Dialect, snapshot *Snapshot) (map[relistKey]int, error) {
+	rows, err := tx.Query(dialect.Rebind(`SELECT item_id, item_rand, quantity FROM AuctionLifecycles
+		WHERE connected_realm_id = ? AND auction_house_id = ? AND first_snapshot_id = ?`),
+		snapshot.ConnectedRealmID, snapshot.AuctionHouseID, snapshot.SnapshotID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	listings := map[relistKey]int{}
+
+	for rows.Next() {
+		var key relistKey
+
+		err = rows.Scan(&key.itemID, &key.itemRand, &key.quantity)
+
+		if err != nil {
+			return nil, err
+		}
+
+		listings[key]++
+	}
+
+	return listings, rows.Err()
+}
+
+// Guesses how an auction ended from the time it had left when it was last seen and how long it has been gone since
+//
+// The dumps do not say, so this is a guess:
+//   - An auction expired if at least half of the time it could have had left has passed.
+//     Its time left is taken to be anywhere in its bucket, so that is when it more likely expired than not.
+//   - Otherwise an auction that can't be bought out, bid-only, was cancelled.
+//   - So was one whose stack of the same item is listed again in the same snapshot, sellers cancel to undercut.
+//   - Everything else was sold.
+func classifyEnding(lastTimeLeft string, gap time.Duration, bidOnly bool, relisted bool) string {
+	if bucket, ok := timeLeftBuckets[lastTimeLeft]; ok && gap >= (bucket[0]+bucket[1])/2 {
+		return OutcomeExpired
+	}
+
+	if bidOnly || relisted {
+		return OutcomeCancelled
+	}
+
+	return OutcomeSold
+}
@@ -0,0 +1,162 @@
+package blackwater
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestClassifyEnding(t *testing.T) {
+	tests := []struct {
+		timeLeft string
+		gap      time.Duration
+		bidOnly  bool
+		relisted bool
+		outcome  string
+	}{
+		{"SHORT", time.Hour, false, false, OutcomeExpired},
+		{"SHORT", 10 * time.Minute, false, false, OutcomeSold},
+		{"MEDIUM", time.Hour, false, false, OutcomeSold},
+		{"MEDIUM", 2 * time.Hour, false, false, OutcomeExpired},
+		{"LONG", time.Hour, false, false, OutcomeSold},
+		{"LONG", 7 * time.Hour, false, false, OutcomeExpired},
+		{"VERY_LONG", 24 * time.Hour, false, false, OutcomeSold},
+		// A gap in the snapshots is long enough for anything to expire
+		{"VERY_LONG", 3 * 24 * time.Hour, true, true, OutcomeExpired},
+		{"LONG", time.Hour, true, false, OutcomeCancelled},
+		{"LONG", time.Hour, false, true, OutcomeCancelled},
+		{"", time.Hour, false, false, OutcomeSold},
+	}
+
+	for _, test := range tests {
+		if outcome := classifyEnding(test.timeLeft, test.gap, test.bidOnly, test.relisted); outcome != test.outcome {
+			t.Errorf("%s gone for %s (bid-only %v, relisted %v): expected %s, got %s",
+				test.timeLeft, test.gap, test.bidOnly, test.relisted, test.outcome, outcome)
+		}
+	}
+}
+
+// Three hourly dumps of one house
+// 1 to 4 end after the first: 1 is sold, 2 expires, 3 is bid-only and 4 is listed again for less as 10.
+// 5 changes its bid and time left and ends after the second.
+var lifecycleTestDumps = []string{
+	`{"auctions": [
+		{"id": 1, "item": {"id": 13444}, "buyout": 1500, "quantity": 1, "time_left": "LONG"},
+		{"id": 2, "item": {"id": 13452}, "buyout": 900, "quantity": 1, "time_left": "SHORT"},
+		{"id": 3, "item": {"id": 10118, "rand": 1017, "seed": 1430265344}, "bid": 5000, "quantity": 1, "time_left": "LONG"},
+		{"id": 4, "item": {"id": 13444}, "buyout": 5000, "quantity": 5, "time_left": "LONG"},
+		{"id": 5, "item": {"id": 5634}, "bid": 100, "buyout": 400, "quantity": 2, "time_left": "VERY_LONG"}
+	]}`,
+	`{"auctions": [
+		{"id": 5, "item": {"id": 5634}, "bid": 150, "buyout": 400, "quantity": 2, "time_left": "LONG"},
+		{"id": 10, "item": {"id": 13444, "modifiers": [{"type": 9, "value": 60}], "bonus_lists": [6654]}, "buyout": 4500, "quantity": 5, "time_left": "VERY_LONG"}
+	]}`,
+	`{"auctions": [
+		{"id": 10, "item": {"id": 13444, "modifiers": [{"type": 9, "value": 60}], "bonus_lists": [6654]}, "buyout": 4500, "quantity": 5, "time_left": "VERY_LONG"}
+	]}`,
+}
+
+// Imports the dumps an hour apart, starting at start, the way the auctions subcommand does
+func importTestDumps(t *testing.T, database *Database, house AuctionColumns, start int64, dumps []string) {
+	t.Helper()
+
+	for i, dump := range dumps {
+		importTime := start + int64(i)*3600
+
+		snapshot, err := BeginSnapshot(database, house, importTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot.AuctionCount, err = InsertAuctionsFrom(database, gzipTestDump(t, dump), importTime, house, snapshot.SnapshotID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = TrackLifecycles(database, snapshot); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = FinishSnapshot(database, snapshot, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrackLifecycles(t *testing.T) {
+	database := &Database{DatabaseType: "sqlite3", Handle: openTestDB(t)}
+	house := AuctionColumns{ConnectedRealmID: 5284, AuctionHouseID: 2, GameVersion: Era}
+	start := int64(1700000000)
+
+	importTestDumps(t, database, house, start, lifecycleTestDumps)
+
+	expected := map[int64]struct {
+		firstSeen int64
+		lastSeen  int64
+		endedAt   sql.NullInt64
+		outcome   sql.NullString
+	}{
+		1:  {start, start, sql.NullInt64{Int64: start + 3600, Valid: true}, sql.NullString{String: OutcomeSold, Valid: true}},
+		2:  {start, start, sql.NullInt64{Int64: start + 3600, Valid: true}, sql.NullString{String: OutcomeExpired, Valid: true}},
+		3:  {start, start, sql.NullInt64{Int64: start + 3600, Valid: true}, sql.NullString{String: OutcomeCancelled, Valid: true}},
+		4:  {start, start, sql.NullInt64{Int64: start + 3600, Valid: true}, sql.NullString{String: OutcomeCancelled, Valid: true}},
+		5:  {start, start + 3600, sql.NullInt64{Int64: start + 7200, Valid: true}, sql.NullString{String: OutcomeSold, Valid: true}},
+		10: {start + 3600, start + 7200, sql.NullInt64{}, sql.NullString{}},
+	}
+
+	rows, err := database.Handle.Query(`SELECT auction_id, CAST(first_seen AS INTEGER), CAST(last_seen AS INTEGER), CAST(ended_at AS INTEGER), outcome
+		FROM AuctionLifecycles WHERE connected_realm_id = 5284`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	seen := 0
+
+	for rows.Next() {
+		var auctionID, firstSeen, lastSeen int64
+		var endedAt sql.NullInt64
+		var outcome sql.NullString
+
+		if err = rows.Scan(&auctionID, &firstSeen, &lastSeen, &endedAt, &outcome); err != nil {
+			t.Fatal(err)
+		}
+
+		seen++
+
+		want, ok := expected[auctionID]
+		if !ok {
+			t.Errorf("unexpected lifecycle of auction %d", auctionID)
+			continue
+		}
+
+		if firstSeen != want.firstSeen || lastSeen != want.lastSeen || endedAt != want.endedAt || outcome != want.outcome {
+			t.Errorf("auction %d: expected %+v, got seen %d to %d, ended %v, %v", auctionID, want, firstSeen, lastSeen, endedAt, outcome)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen != len(expected) {
+		t.Errorf("expected %d lifecycles, got %d", len(expected), seen)
+	}
+
+	// One row per auction, however often its time left changed
+	var bid int
+	var firstTimeLeft, lastTimeLeft string
+
+	err = database.Handle.QueryRow(`SELECT bid, first_time_left, last_time_left FROM AuctionLifecycles WHERE auction_id = 5`).
+		Scan(&bid, &firstTimeLeft, &lastTimeLeft)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bid != 150 || firstTimeLeft != "VERY_LONG" || lastTimeLeft != "LONG" {
+		t.Errorf("expected the last bid 150 and VERY_LONG to LONG, got %d and %s to %s", bid, firstTimeLeft, lastTimeLeft)
+	}
+}
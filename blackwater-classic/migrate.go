@@ -0,0 +1,317 @@
+package blackwater
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in migrations/<dialect>/, e.g. migrations/mysql/0002_snapshots.up.sql
+// Every migration has an up and a down file and is numbered, they are applied in order.
+// migrations/legacy/ converts databases from before migrations, see convertLegacySchema.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Keeps track of the applied migrations, it is the same in every dialect
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations(
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at BIGINT NOT NULL)`
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt int64 // Unix time, 0 if the migration has not been applied
+}
+
+// Returns the migrations of a dialect, ordered by version
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", dialect.String())
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s/%s", dir, entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d of %s has two names: %s and %s", version, dialect, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s of %s needs an up and a down file", migration.Version, migration.Name, dialect)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Returns every migration of the database and whether it has been applied
+func MigrationStatus(db *Database) ([]MigrationState, error) {
+	dialect := db.Dialect()
+
+	migrations, err := Migrations(dialect)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Handle.Exec(createSchemaMigrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Handle.Query(`SELECT version, applied_at FROM schema_migrations`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]int64{}
+
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+
+		err = rows.Scan(&version, &appliedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	states := []MigrationState{}
+
+	for _, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		states = append(states, MigrationState{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return states, nil
+}
+
+// Applies every pending migration, returns the migrations that were applied
+func MigrateUp(db *Database) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+
+	for _, state := range states {
+		if state.Applied {
+			continue
+		}
+
+		log.Printf("Applying migration %d_%s\n", state.Version, state.Name)
+
+		script := state.Up
+
+		if state.Version == 1 {
+			script, err = convertLegacySchema(db, script)
+
+			if err != nil {
+				return applied, err
+			}
+		}
+
+		err = runMigration(db, script,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, state.Version, state.Name, time.Now().Unix())
+
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+		}
+
+		applied = append(applied, state.Migration)
+	}
+
+	return applied, nil
+}
+
+// Wraps the first migration in the conversion of a database that SetupDatabase created before migrations existed
+// Its tables exist already, so the first migration would skip them and leave the old columns and keys.
+// Only SQLite databases were created that way, other dialects with the old schema are refused.
+func convertLegacySchema(db *Database, up string) (string, error) {
+	// The auction house hrefs moved from ConnectedRealms to AuctionHouses with the first migration
+	_, err := db.Handle.Exec(`SELECT alliance_ah_href FROM ConnectedRealms WHERE 1 = 0`)
+
+	if err != nil {
+		return up, nil
+	}
+
+	if db.Dialect() != SQLite {
+		return "", fmt.Errorf("%w: only %s databases can be converted, %s can't", ErrLegacySchema, SQLite, db.Dialect())
+	}
+
+	log.Println("Converting the tables from before migrations")
+
+	before, err := fs.ReadFile(migrationFiles, "migrations/legacy/sqlite3.before.sql")
+
+	if err != nil {
+		return "", err
+	}
+
+	after, err := fs.ReadFile(migrationFiles, "migrations/legacy/sqlite3.after.sql")
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(before) + "\n" + up + "\n" + string(after), nil
+}
+
+// Reverts the last steps applied migrations, returns the migrations that were reverted
+func MigrateDown(db *Database, steps int) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		state := states[i]
+
+		if !state.Applied {
+			continue
+		}
+
+		log.Printf("Reverting migration %d_%s\n", state.Version, state.Name)
+
+		err = runMigration(db, state.Down, `DELETE FROM schema_migrations WHERE version = ?`, state.Version)
+
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+		}
+
+		reverted = append(reverted, state.Migration)
+	}
+
+	return reverted, nil
+}
+
+// Runs the statements of a migration script and the record query that updates schema_migrations, in one transaction
+// MySQL commits DDL statements right away, a failed migration may have to be cleaned up by hand there.
+func runMigration(db *Database, script string, record string, args ...interface{}) error {
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(script) {
+		_, err = tx.Exec(statement)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(db.Dialect().Rebind(record), args...)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Splits a script into its statements
+// Statements end with a ; at the end of a line, lines that start with -- are comments.
+func splitStatements(script string) []string {
+	statements := []string{}
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
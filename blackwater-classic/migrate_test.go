@@ -0,0 +1,250 @@
+package blackwater
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	expected, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every dialect has the same migrations, numbered from 1
+	for _, dialect := range []Dialect{SQLite, MySQL, Postgres} {
+		migrations, err := Migrations(dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", dialect, err)
+		}
+
+		if len(migrations) != len(expected) {
+			t.Fatalf("%s has %d migrations, sqlite3 has %d", dialect, len(migrations), len(expected))
+		}
+
+		for i, migration := range migrations {
+			if migration.Version != i+1 || migration.Name != expected[i].Name {
+				t.Errorf("%s: expected migration %d_%s, got %d_%s", dialect, i+1, expected[i].Name, migration.Version, migration.Name)
+			}
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	database := NewLocalDatabase(filepath.Join(t.TempDir(), "blackwater.db"))
+
+	if err := SetupDatabase(&database); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer database.CloseConnection()
+
+	tableExists := func(name string) bool {
+		var n int
+		err := database.Handle.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return n == 1
+	}
+
+	states, err := MigrationStatus(&database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range states {
+		if !state.Applied || state.AppliedAt == 0 {
+			t.Errorf("expected %d_%s to be applied by SetupDatabase", state.Version, state.Name)
+		}
+	}
+
+	// Nothing is pending
+	applied, err := MigrateUp(&database)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected no pending migrations, got %v %v", applied, err)
+	}
+
+	reverted, err := MigrateDown(&database, len(states))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != len(states) || reverted[0].Version != states[len(states)-1].Version {
+		t.Errorf("unexpected reverted migrations: %v", reverted)
+	}
+
+	if tableExists("Auctions") || tableExists("ConnectedRealms") {
+		t.Error("expected the tables to be dropped")
+	}
+
+	applied, err = MigrateUp(&database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(states) || !tableExists("Auctions") {
+		t.Errorf("expected every migration to be applied again, got %v", applied)
+	}
+
+	var factions int
+	if err := database.Handle.QueryRow(`SELECT COUNT(*) FROM Factions`).Scan(&factions); err != nil {
+		t.Fatal(err)
+	}
+
+	if factions != 3 {
+		t.Errorf("expected 3 factions, got %d", factions)
+	}
+}
+
+// The tables SetupDatabase created before migrations existed
+var legacySchema = []string{
+	`CREATE TABLE IF NOT EXISTS ConnectedRealms(
+		connected_realm_id INTEGER NOT NULL PRIMARY KEY,
+		region INTEGER,
+		name TEXT,
+		timezone TEXT,
+		alliance_ah_href TEXT,
+		horde_ah_href TEXT,
+		neutral_ah_href TEXT);`,
+	`CREATE TABLE IF NOT EXISTS Items(
+		item_id INTEGER NOT NULL PRIMARY KEY,
+		item_class_id INTEGER,
+		item_class STRING,
+		item_subclass_id INTEGER,
+		item_subclass STRING,
+		quality STRING,
+		name TEXT);`,
+	`CREATE TABLE IF NOT EXISTS Factions(
+		faction_id INTEGER PRIMARY KEY,
+		faction_name TEXT);`,
+	`INSERT INTO Factions(faction_id, faction_name) VALUES(0, "Alliance")`,
+	`INSERT INTO Factions(faction_id, faction_name) VALUES(1, "Horde")`,
+	`INSERT INTO Factions(faction_id, faction_name) VALUES(2, "Neutral")`,
+	`CREATE TABLE IF NOT EXISTS Auctions(
+		id INTEGER NOT NULL PRIMARY KEY,
+		auction_id INTEGER,
+		buyout INTEGER,
+		quantity INTEGER,
+		time_left TEXT,
+		timestamp DATETIME,
+		item_id INTEGER,
+		connected_realm_id INTEGER,
+		faction_id INTEGER,
+		FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
+		FOREIGN KEY(item_id) REFERENCES Items(item_id),
+		FOREIGN KEY(faction_id) REFERENCES Factions(faction_id),
+		UNIQUE(auction_id, connected_realm_id, time_left));`,
+	`INSERT INTO ConnectedRealms (connected_realm_id, region, name, alliance_ah_href, horde_ah_href, neutral_ah_href)
+		VALUES (5284, 0, 'Nethergarde Keep', 'https://eu.api.blizzard.com/data/wow/connected-realm/5284/auctions/2',
+		'https://eu.api.blizzard.com/data/wow/connected-realm/5284/auctions/6', '')`,
+	`INSERT INTO Items (item_id, item_class, name) VALUES (2589, 'Trade Goods', 'Linen Cloth')`,
+	`INSERT INTO Auctions (auction_id, buyout, quantity, time_left, timestamp, item_id, connected_realm_id, faction_id)
+		VALUES (1, 1000, 10, 'LONG', 1600000000, 2589, 5284, 0), (2, 0, 1, 'SHORT', 1600000000, 2589, 5284, 1)`,
+}
+
+func TestMigrateLegacySchema(t *testing.T) {
+	database := NewLocalDatabase(filepath.Join(t.TempDir(), "blackwater.db"))
+
+	if err := database.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer database.CloseConnection()
+
+	for _, statement := range legacySchema {
+		if _, err := database.Handle.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SetupDatabase(&database); err != nil {
+		t.Fatal(err)
+	}
+
+	var gameVersion int
+	var name string
+	err := database.Handle.QueryRow(`SELECT game_version, name FROM ConnectedRealms WHERE connected_realm_id = 5284`).Scan(&gameVersion, &name)
+	if err != nil || gameVersion != int(Era) || name != "Nethergarde Keep" {
+		t.Errorf("expected the connected realm to be kept, got %d %q %v", gameVersion, name, err)
+	}
+
+	var houses int
+	if err = database.Handle.QueryRow(`SELECT COUNT(*) FROM AuctionHouses WHERE auction_house_id IN (2, 6)`).Scan(&houses); err != nil || houses != 2 {
+		t.Errorf("expected the hrefs to become houses 2 and 6, got %d %v", houses, err)
+	}
+
+	// Items are keyed by game version now
+	if _, err = database.Handle.Exec(`INSERT INTO Items (item_id, game_version, name) VALUES (2589, 2, 'Linen Cloth')`); err != nil {
+		t.Errorf("expected a Retail item next to the Era item: %v", err)
+	}
+
+	rows, err := database.Handle.Query(`SELECT auction_id, auction_house_id, game_version, snapshot_id, buyout, unit_price FROM Auctions ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	type auction struct {
+		AuctionID, AuctionHouseID, GameVersion int
+		SnapshotID, Buyout, UnitPrice          sql.NullInt64
+	}
+
+	auctions := []auction{}
+	for rows.Next() {
+		var a auction
+		if err = rows.Scan(&a.AuctionID, &a.AuctionHouseID, &a.GameVersion, &a.SnapshotID, &a.Buyout, &a.UnitPrice); err != nil {
+			t.Fatal(err)
+		}
+
+		auctions = append(auctions, a)
+	}
+
+	expected := []auction{
+		{AuctionID: 1, AuctionHouseID: 2, Buyout: sql.NullInt64{Int64: 1000, Valid: true}, UnitPrice: sql.NullInt64{Int64: 100, Valid: true}},
+		{AuctionID: 2, AuctionHouseID: 6},
+	}
+
+	if !reflect.DeepEqual(auctions, expected) {
+		t.Errorf("expected the auction history to be kept, got %+v", auctions)
+	}
+
+	var legacyTables int
+	if err = database.Handle.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name LIKE 'Legacy%'`).Scan(&legacyTables); err != nil || legacyTables != 0 {
+		t.Errorf("expected the old tables to be dropped, %d are left", legacyTables)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`-- A comment; with a semicolon
+CREATE TABLE A(
+    id INTEGER);
+
+INSERT INTO A (id) VALUES (1),
+    (2);
+DROP TABLE B`)
+
+	expected := []string{
+		"CREATE TABLE A(\n    id INTEGER);",
+		"INSERT INTO A (id) VALUES (1),\n    (2);",
+		"DROP TABLE B",
+	}
+
+	if len(statements) != len(expected) {
+		t.Fatalf("expected %d statements, got %q", len(expected), statements)
+	}
+
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("statement %d: got %q, expected %q", i, statements[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,25 @@
+-- Runs after 0001_initial_schema, copies the old tables into the new ones and drops them
+-- Everything before migrations was Era, so the game version is left at its default.
+INSERT INTO ConnectedRealms (connected_realm_id, region, name, timezone)
+    SELECT connected_realm_id, region, name, timezone FROM LegacyConnectedRealms;
+
+-- The auction house hrefs become houses 2, 6 and 7, their names are filled in by the next update
+INSERT INTO AuctionHouses (connected_realm_id, auction_house_id, faction_id, href)
+    SELECT connected_realm_id, 2, 0, alliance_ah_href FROM LegacyConnectedRealms WHERE alliance_ah_href <> '';
+INSERT INTO AuctionHouses (connected_realm_id, auction_house_id, faction_id, href)
+    SELECT connected_realm_id, 6, 1, horde_ah_href FROM LegacyConnectedRealms WHERE horde_ah_href <> '';
+INSERT INTO AuctionHouses (connected_realm_id, auction_house_id, faction_id, href)
+    SELECT connected_realm_id, 7, 2, neutral_ah_href FROM LegacyConnectedRealms WHERE neutral_ah_href <> '';
+
+INSERT INTO Items (item_id, item_class_id, item_class, item_subclass_id, item_subclass, quality, name)
+    SELECT item_id, item_class_id, item_class, item_subclass_id, item_subclass, quality, name FROM LegacyItems;
+
+-- Auctions keep their IDs, the house follows from the faction
+INSERT INTO Auctions (id, auction_id, buyout, quantity, time_left, timestamp, item_id, connected_realm_id, auction_house_id, faction_id)
+    SELECT id, auction_id, buyout, quantity, time_left, timestamp, item_id, connected_realm_id,
+        CASE faction_id WHEN 0 THEN 2 WHEN 1 THEN 6 WHEN 2 THEN 7 END, faction_id
+    FROM LegacyAuctions;
+
+DROP TABLE LegacyAuctions;
+DROP TABLE LegacyItems;
+DROP TABLE LegacyConnectedRealms;
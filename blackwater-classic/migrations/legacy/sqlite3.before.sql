@@ -0,0 +1,5 @@
+-- Runs before 0001_initial_schema on a database created before migrations existed
+-- The old tables are moved aside, so that the first migration creates the new ones. Factions did not change.
+ALTER TABLE Auctions RENAME TO LegacyAuctions;
+ALTER TABLE Items RENAME TO LegacyItems;
+ALTER TABLE ConnectedRealms RENAME TO LegacyConnectedRealms;
@@ -0,0 +1,111 @@
+-- Same tables as the sqlite3 migration
+-- Columns that are part of a key are VARCHAR, since MySQL can't index TEXT without a prefix length.
+-- Auctions have no foreign key to Items, items are cached after their auctions have been imported.
+-- The realm history has no foreign keys to the realms either, so that reset-realms can delete the realms.
+-- 0008_foreign_keys adds the keys of Auctions and Snapshots.
+-- Timestamps are unix times in seconds.
+
+-- Blizzard numbers the connected realms of every region and game version from one pool, e.g. 5284 is only the Era realm in EU,
+-- so the ID alone is the key. update refuses a connected realm whose ID is stored for another region or game version.
+CREATE TABLE IF NOT EXISTS ConnectedRealms(
+    connected_realm_id INT NOT NULL PRIMARY KEY,
+    region INT,
+    game_version INT NOT NULL DEFAULT 0,
+    name VARCHAR(255),
+    timezone VARCHAR(64)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS Realms(
+    realm_id INT NOT NULL PRIMARY KEY,
+    connected_realm_id INT NOT NULL,
+    region INT,
+    game_version INT NOT NULL DEFAULT 0,
+    name VARCHAR(255),
+    slug VARCHAR(255),
+    locale VARCHAR(8),
+    timezone VARCHAR(64),
+    category VARCHAR(64),
+    type VARCHAR(32),
+    is_tournament BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS RealmHistory(
+    realm_id INT NOT NULL,
+    connected_realm_id INT NOT NULL,
+    timestamp BIGINT NOT NULL,
+    PRIMARY KEY(realm_id, timestamp)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS ConnectedRealmStatus(
+    connected_realm_id INT NOT NULL,
+    timestamp BIGINT NOT NULL,
+    status VARCHAR(16),
+    population VARCHAR(16),
+    has_queue BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY(connected_realm_id, timestamp)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS Factions(
+    faction_id INT NOT NULL PRIMARY KEY,
+    faction_name VARCHAR(32)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS AuctionHouses(
+    connected_realm_id INT NOT NULL,
+    auction_house_id INT NOT NULL,
+    faction_id INT,
+    name VARCHAR(255),
+    href TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    game_version INT NOT NULL DEFAULT 0,
+    PRIMARY KEY(connected_realm_id, auction_house_id),
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS Items(
+    item_id INT NOT NULL,
+    game_version INT NOT NULL DEFAULT 0,
+    item_class_id INT,
+    item_class VARCHAR(255),
+    item_subclass_id INT,
+    item_subclass VARCHAR(255),
+    quality VARCHAR(32),
+    name VARCHAR(255),
+    locale VARCHAR(8),
+    PRIMARY KEY(item_id, game_version)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS Auctions(
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    auction_id BIGINT,
+    buyout BIGINT,
+    quantity INT,
+    time_left VARCHAR(16),
+    timestamp BIGINT,
+    item_id INT,
+    connected_realm_id INT,
+    auction_house_id INT,
+    faction_id INT,
+    game_version INT NOT NULL DEFAULT 0,
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id),
+    UNIQUE(auction_id, connected_realm_id, time_left)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS Commodities(
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    auction_id BIGINT,
+    region INT,
+    timestamp BIGINT,
+    item_id INT,
+    quantity INT,
+    unit_price BIGINT,
+    time_left VARCHAR(16),
+    UNIQUE(auction_id, region, timestamp),
+    INDEX commodities_region_timestamp (region, timestamp)
+) DEFAULT CHARSET = utf8mb4;
+
+-- Indexed by faction ID, see Alliance, Horde and Neutral
+INSERT INTO Factions (faction_id, faction_name) VALUES (0, 'Alliance'), (1, 'Horde'), (2, 'Neutral')
+    ON DUPLICATE KEY UPDATE faction_id = faction_id;
@@ -0,0 +1,2 @@
+ALTER TABLE Auctions DROP INDEX auctions_snapshot_id, DROP COLUMN snapshot_id;
+DROP TABLE IF EXISTS Snapshots;
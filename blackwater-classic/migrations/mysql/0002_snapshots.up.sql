@@ -0,0 +1,20 @@
+-- Same table as the sqlite3 migration, fetched_at and last_modified are unix times in seconds
+
+CREATE TABLE IF NOT EXISTS Snapshots(
+    snapshot_id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    connected_realm_id INT NOT NULL,
+    auction_house_id INT NOT NULL,
+    faction_id INT,
+    game_version INT NOT NULL DEFAULT 0,
+    fetched_at BIGINT NOT NULL,
+    last_modified BIGINT,
+    auction_count INT NOT NULL DEFAULT 0,
+    byte_size BIGINT NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id),
+    INDEX snapshots_house_fetched_at (connected_realm_id, auction_house_id, fetched_at)
+) DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE Auctions ADD COLUMN snapshot_id BIGINT, ADD INDEX auctions_snapshot_id (snapshot_id);
@@ -0,0 +1 @@
+ALTER TABLE AuctionHouses DROP COLUMN last_modified;
@@ -0,0 +1,2 @@
+-- Same column as the sqlite3 migration, a unix time in seconds
+ALTER TABLE AuctionHouses ADD COLUMN last_modified BIGINT;
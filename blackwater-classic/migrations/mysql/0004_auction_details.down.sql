@@ -0,0 +1,9 @@
+UPDATE Auctions SET buyout = 0 WHERE buyout IS NULL;
+ALTER TABLE Auctions
+    DROP INDEX auctions_item_rand,
+    DROP COLUMN item_bonus_lists,
+    DROP COLUMN item_modifiers,
+    DROP COLUMN item_seed,
+    DROP COLUMN item_rand,
+    DROP COLUMN unit_price,
+    DROP COLUMN bid;
@@ -0,0 +1,12 @@
+-- Same columns as the sqlite3 migration
+ALTER TABLE Auctions
+    ADD COLUMN bid BIGINT,
+    ADD COLUMN unit_price BIGINT,
+    ADD COLUMN item_rand INT,
+    ADD COLUMN item_seed BIGINT,
+    ADD COLUMN item_modifiers TEXT,
+    ADD COLUMN item_bonus_lists TEXT,
+    ADD INDEX auctions_item_rand (item_id, item_rand);
+
+UPDATE Auctions SET buyout = NULL WHERE buyout = 0;
+UPDATE Auctions SET unit_price = (buyout + quantity DIV 2) DIV quantity WHERE buyout > 0 AND quantity > 0;
@@ -0,0 +1,26 @@
+-- Same table as the sqlite3 migration, timestamps are unix times in seconds
+
+CREATE TABLE IF NOT EXISTS AuctionLifecycles(
+    connected_realm_id INT NOT NULL,
+    auction_id BIGINT NOT NULL,
+    auction_house_id INT NOT NULL,
+    faction_id INT,
+    game_version INT NOT NULL DEFAULT 0,
+    item_id INT,
+    item_rand INT,
+    quantity INT,
+    bid BIGINT,
+    buyout BIGINT,
+    unit_price BIGINT,
+    first_seen BIGINT NOT NULL,
+    first_snapshot_id BIGINT NOT NULL,
+    first_time_left VARCHAR(16),
+    last_seen BIGINT NOT NULL,
+    last_snapshot_id BIGINT NOT NULL,
+    last_time_left VARCHAR(16),
+    ended_at BIGINT,
+    outcome VARCHAR(16),
+    PRIMARY KEY(connected_realm_id, auction_id),
+    INDEX lifecycles_house_ended_at (connected_realm_id, auction_house_id, ended_at),
+    INDEX lifecycles_item_outcome (item_id, outcome)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS PendingAuctions;
+DROP TABLE IF EXISTS AuctionChanges;
+
+ALTER TABLE AuctionLifecycles
+    DROP COLUMN item_bonus_lists,
+    DROP COLUMN item_modifiers,
+    DROP COLUMN item_seed;
@@ -0,0 +1,40 @@
+-- Same tables as the sqlite3 migration, changed_at and timestamp are unix times in seconds
+ALTER TABLE AuctionLifecycles
+    ADD COLUMN item_seed BIGINT,
+    ADD COLUMN item_modifiers TEXT,
+    ADD COLUMN item_bonus_lists TEXT;
+
+CREATE TABLE IF NOT EXISTS AuctionChanges(
+    connected_realm_id INT NOT NULL,
+    auction_id BIGINT NOT NULL,
+    changed_at BIGINT NOT NULL,
+    auction_house_id INT NOT NULL,
+    snapshot_id BIGINT NOT NULL,
+    time_left VARCHAR(16),
+    bid BIGINT,
+    PRIMARY KEY(connected_realm_id, auction_id, changed_at),
+    INDEX auction_changes_house_changed_at (connected_realm_id, auction_house_id, changed_at)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS PendingAuctions(
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    auction_id BIGINT,
+    bid BIGINT,
+    buyout BIGINT,
+    unit_price BIGINT,
+    quantity INT,
+    time_left VARCHAR(16),
+    timestamp BIGINT,
+    item_id INT,
+    item_rand INT,
+    item_seed BIGINT,
+    item_modifiers TEXT,
+    item_bonus_lists TEXT,
+    connected_realm_id INT,
+    auction_house_id INT,
+    faction_id INT,
+    game_version INT NOT NULL DEFAULT 0,
+    snapshot_id BIGINT,
+    UNIQUE(auction_id, connected_realm_id, time_left),
+    INDEX pending_auctions_snapshot_id (snapshot_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+ALTER TABLE PendingAuctions DROP INDEX pending_auctions_last_snapshot_id, DROP COLUMN last_snapshot_id;
+
+-- Before this migration snapshot_id was the last snapshot
+UPDATE Auctions SET snapshot_id = last_snapshot_id WHERE last_snapshot_id IS NOT NULL;
+ALTER TABLE Auctions DROP INDEX auctions_last_snapshot_id, DROP COLUMN last_snapshot_id;
@@ -0,0 +1,11 @@
+-- The placeholder realms and houses of the up migration are kept
+ALTER TABLE Auctions
+    DROP FOREIGN KEY auctions_last_snapshot_fk,
+    DROP FOREIGN KEY auctions_snapshot_fk,
+    DROP FOREIGN KEY auctions_connected_realm_fk;
+
+ALTER TABLE Auctions DROP INDEX auctions_connected_realm_id;
+
+ALTER TABLE Snapshots
+    DROP FOREIGN KEY snapshots_connected_realm_fk,
+    DROP FOREIGN KEY snapshots_auction_house_fk;
@@ -0,0 +1,43 @@
+-- Same keys as the postgres migration
+-- reset-realms used to delete realms and houses that still had snapshots and auctions, convert-auctions adds snapshots for the houses of Auctions,
+-- those get a row again before the keys are added: the connected realm without a name, the house disabled.
+INSERT INTO ConnectedRealms (connected_realm_id, game_version)
+    SELECT connected_realm_id, MIN(game_version) FROM (
+        SELECT connected_realm_id, game_version FROM Snapshots
+        UNION
+        SELECT connected_realm_id, game_version FROM Auctions WHERE connected_realm_id IS NOT NULL
+    ) R
+    GROUP BY connected_realm_id
+    ON DUPLICATE KEY UPDATE connected_realm_id = ConnectedRealms.connected_realm_id;
+
+INSERT INTO AuctionHouses (connected_realm_id, auction_house_id, faction_id, enabled, game_version)
+    SELECT connected_realm_id, auction_house_id, MIN(faction_id), FALSE, MIN(game_version) FROM (
+        SELECT connected_realm_id, auction_house_id, faction_id, game_version FROM Snapshots
+        UNION
+        SELECT connected_realm_id, auction_house_id, faction_id, game_version FROM Auctions
+        WHERE connected_realm_id IS NOT NULL AND auction_house_id IS NOT NULL
+    ) H
+    GROUP BY connected_realm_id, auction_house_id
+    ON DUPLICATE KEY UPDATE connected_realm_id = AuctionHouses.connected_realm_id;
+
+UPDATE Auctions SET snapshot_id = NULL
+    WHERE snapshot_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Snapshots S WHERE S.snapshot_id = Auctions.snapshot_id);
+
+UPDATE Auctions SET last_snapshot_id = NULL
+    WHERE last_snapshot_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Snapshots S WHERE S.snapshot_id = Auctions.last_snapshot_id);
+
+-- snapshots_house_fetched_at, auctions_snapshot_id and auctions_last_snapshot_id already index the keys
+ALTER TABLE Snapshots
+    ADD CONSTRAINT snapshots_auction_house_fk
+        FOREIGN KEY (connected_realm_id, auction_house_id) REFERENCES AuctionHouses(connected_realm_id, auction_house_id),
+    ADD CONSTRAINT snapshots_connected_realm_fk
+        FOREIGN KEY (connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id);
+
+ALTER TABLE Auctions
+    ADD INDEX auctions_connected_realm_id (connected_realm_id),
+    ADD CONSTRAINT auctions_connected_realm_fk
+        FOREIGN KEY (connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
+    ADD CONSTRAINT auctions_snapshot_fk
+        FOREIGN KEY (snapshot_id) REFERENCES Snapshots(snapshot_id),
+    ADD CONSTRAINT auctions_last_snapshot_fk
+        FOREIGN KEY (last_snapshot_id) REFERENCES Snapshots(snapshot_id);
@@ -0,0 +1,95 @@
+-- Same tables as the sqlite3 migration, timestamps are TIMESTAMPTZ and flags BOOLEAN
+-- Like in the mysql migration, Auctions have no foreign key to Items, 0008_foreign_keys adds their keys to the realms.
+
+-- Blizzard numbers the connected realms of every region and game version from one pool, e.g. 5284 is only the Era realm in EU,
+-- so the ID alone is the key. update refuses a connected realm whose ID is stored for another region or game version.
+CREATE TABLE IF NOT EXISTS ConnectedRealms(
+    connected_realm_id INTEGER NOT NULL PRIMARY KEY,
+    region INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    name TEXT,
+    timezone TEXT);
+
+CREATE TABLE IF NOT EXISTS Realms(
+    realm_id INTEGER NOT NULL PRIMARY KEY,
+    connected_realm_id INTEGER NOT NULL REFERENCES ConnectedRealms(connected_realm_id),
+    region INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    name TEXT,
+    slug TEXT,
+    locale TEXT,
+    timezone TEXT,
+    category TEXT,
+    type TEXT,
+    is_tournament BOOLEAN NOT NULL DEFAULT FALSE);
+
+CREATE TABLE IF NOT EXISTS RealmHistory(
+    realm_id INTEGER NOT NULL,
+    connected_realm_id INTEGER NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY(realm_id, timestamp));
+
+CREATE TABLE IF NOT EXISTS ConnectedRealmStatus(
+    connected_realm_id INTEGER NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    status TEXT,
+    population TEXT,
+    has_queue BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY(connected_realm_id, timestamp));
+
+CREATE TABLE IF NOT EXISTS Factions(
+    faction_id INTEGER NOT NULL PRIMARY KEY,
+    faction_name TEXT);
+
+CREATE TABLE IF NOT EXISTS AuctionHouses(
+    connected_realm_id INTEGER NOT NULL REFERENCES ConnectedRealms(connected_realm_id),
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER REFERENCES Factions(faction_id),
+    name TEXT,
+    href TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(connected_realm_id, auction_house_id));
+
+CREATE TABLE IF NOT EXISTS Items(
+    item_id INTEGER NOT NULL,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    item_class_id INTEGER,
+    item_class TEXT,
+    item_subclass_id INTEGER,
+    item_subclass TEXT,
+    quality TEXT,
+    name TEXT,
+    locale TEXT,
+    PRIMARY KEY(item_id, game_version));
+
+CREATE TABLE IF NOT EXISTS Auctions(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    auction_id BIGINT,
+    buyout BIGINT,
+    quantity INTEGER,
+    time_left TEXT,
+    timestamp TIMESTAMPTZ,
+    item_id INTEGER,
+    connected_realm_id INTEGER,
+    auction_house_id INTEGER,
+    faction_id INTEGER REFERENCES Factions(faction_id),
+    game_version INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(auction_id, connected_realm_id, time_left));
+
+CREATE TABLE IF NOT EXISTS Commodities(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    auction_id BIGINT,
+    region INTEGER,
+    timestamp TIMESTAMPTZ,
+    item_id INTEGER,
+    quantity INTEGER,
+    unit_price BIGINT,
+    time_left TEXT,
+    UNIQUE(auction_id, region, timestamp));
+
+CREATE INDEX IF NOT EXISTS commodities_region_timestamp ON Commodities(region, timestamp);
+
+-- Indexed by faction ID, see Alliance, Horde and Neutral
+INSERT INTO Factions (faction_id, faction_name) VALUES (0, 'Alliance'), (1, 'Horde'), (2, 'Neutral')
+    ON CONFLICT (faction_id) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Same table as the sqlite3 migration, fetched_at and last_modified are TIMESTAMPTZ
+
+CREATE TABLE IF NOT EXISTS Snapshots(
+    snapshot_id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    connected_realm_id INTEGER NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER REFERENCES Factions(faction_id),
+    game_version INTEGER NOT NULL DEFAULT 0,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    last_modified TIMESTAMPTZ,
+    auction_count INTEGER NOT NULL DEFAULT 0,
+    byte_size BIGINT NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT);
+
+CREATE INDEX IF NOT EXISTS snapshots_house_fetched_at ON Snapshots(connected_realm_id, auction_house_id, fetched_at);
+
+ALTER TABLE Auctions ADD COLUMN IF NOT EXISTS snapshot_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS auctions_snapshot_id ON Auctions(snapshot_id);
@@ -0,0 +1 @@
+ALTER TABLE AuctionHouses DROP COLUMN IF EXISTS last_modified;
@@ -0,0 +1,2 @@
+-- Same column as the sqlite3 migration
+ALTER TABLE AuctionHouses ADD COLUMN IF NOT EXISTS last_modified TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS auctions_item_rand;
+UPDATE Auctions SET buyout = 0 WHERE buyout IS NULL;
+ALTER TABLE Auctions
+    DROP COLUMN IF EXISTS item_bonus_lists,
+    DROP COLUMN IF EXISTS item_modifiers,
+    DROP COLUMN IF EXISTS item_seed,
+    DROP COLUMN IF EXISTS item_rand,
+    DROP COLUMN IF EXISTS unit_price,
+    DROP COLUMN IF EXISTS bid;
@@ -0,0 +1,13 @@
+-- Same columns as the sqlite3 migration
+ALTER TABLE Auctions
+    ADD COLUMN IF NOT EXISTS bid BIGINT,
+    ADD COLUMN IF NOT EXISTS unit_price BIGINT,
+    ADD COLUMN IF NOT EXISTS item_rand INTEGER,
+    ADD COLUMN IF NOT EXISTS item_seed BIGINT,
+    ADD COLUMN IF NOT EXISTS item_modifiers TEXT,
+    ADD COLUMN IF NOT EXISTS item_bonus_lists TEXT;
+
+UPDATE Auctions SET buyout = NULL WHERE buyout = 0;
+UPDATE Auctions SET unit_price = (buyout + quantity / 2) / quantity WHERE buyout > 0 AND quantity > 0;
+
+CREATE INDEX IF NOT EXISTS auctions_item_rand ON Auctions(item_id, item_rand);
@@ -0,0 +1,26 @@
+-- Same table as the sqlite3 migration, timestamps are TIMESTAMPTZ
+
+CREATE TABLE IF NOT EXISTS AuctionLifecycles(
+    connected_realm_id INTEGER NOT NULL,
+    auction_id BIGINT NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    item_id INTEGER,
+    item_rand INTEGER,
+    quantity INTEGER,
+    bid BIGINT,
+    buyout BIGINT,
+    unit_price BIGINT,
+    first_seen TIMESTAMPTZ NOT NULL,
+    first_snapshot_id BIGINT NOT NULL,
+    first_time_left TEXT,
+    last_seen TIMESTAMPTZ NOT NULL,
+    last_snapshot_id BIGINT NOT NULL,
+    last_time_left TEXT,
+    ended_at TIMESTAMPTZ,
+    outcome TEXT,
+    PRIMARY KEY(connected_realm_id, auction_id));
+
+CREATE INDEX IF NOT EXISTS lifecycles_house_ended_at ON AuctionLifecycles(connected_realm_id, auction_house_id, ended_at);
+CREATE INDEX IF NOT EXISTS lifecycles_item_outcome ON AuctionLifecycles(item_id, outcome);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS PendingAuctions;
+DROP TABLE IF EXISTS AuctionChanges;
+
+ALTER TABLE AuctionLifecycles
+    DROP COLUMN IF EXISTS item_bonus_lists,
+    DROP COLUMN IF EXISTS item_modifiers,
+    DROP COLUMN IF EXISTS item_seed;
@@ -0,0 +1,40 @@
+-- Same tables as the sqlite3 migration, changed_at and timestamp are TIMESTAMPTZ
+ALTER TABLE AuctionLifecycles
+    ADD COLUMN IF NOT EXISTS item_seed BIGINT,
+    ADD COLUMN IF NOT EXISTS item_modifiers TEXT,
+    ADD COLUMN IF NOT EXISTS item_bonus_lists TEXT;
+
+CREATE TABLE IF NOT EXISTS AuctionChanges(
+    connected_realm_id INTEGER NOT NULL,
+    auction_id BIGINT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    snapshot_id BIGINT NOT NULL,
+    time_left TEXT,
+    bid BIGINT,
+    PRIMARY KEY(connected_realm_id, auction_id, changed_at));
+
+CREATE INDEX IF NOT EXISTS auction_changes_house_changed_at ON AuctionChanges(connected_realm_id, auction_house_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS PendingAuctions(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    auction_id BIGINT,
+    bid BIGINT,
+    buyout BIGINT,
+    unit_price BIGINT,
+    quantity INTEGER,
+    time_left TEXT,
+    timestamp TIMESTAMPTZ,
+    item_id INTEGER,
+    item_rand INTEGER,
+    item_seed BIGINT,
+    item_modifiers TEXT,
+    item_bonus_lists TEXT,
+    connected_realm_id INTEGER,
+    auction_house_id INTEGER,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    snapshot_id BIGINT,
+    UNIQUE(auction_id, connected_realm_id, time_left));
+
+CREATE INDEX IF NOT EXISTS pending_auctions_snapshot_id ON PendingAuctions(snapshot_id);
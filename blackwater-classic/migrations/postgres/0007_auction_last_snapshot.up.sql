@@ -0,0 +1,9 @@
+-- snapshot_id is the first snapshot a row of Auctions was seen in, last_snapshot_id the last one
+-- The row was listed in every complete snapshot of its house in between.
+-- Rows from before this migration only know their last snapshot, so they start there as well.
+ALTER TABLE Auctions ADD COLUMN IF NOT EXISTS last_snapshot_id BIGINT;
+UPDATE Auctions SET last_snapshot_id = snapshot_id;
+CREATE INDEX IF NOT EXISTS auctions_last_snapshot_id ON Auctions(last_snapshot_id);
+
+ALTER TABLE PendingAuctions ADD COLUMN IF NOT EXISTS last_snapshot_id BIGINT;
+CREATE INDEX IF NOT EXISTS pending_auctions_last_snapshot_id ON PendingAuctions(last_snapshot_id);
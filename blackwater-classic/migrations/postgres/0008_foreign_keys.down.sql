@@ -0,0 +1,7 @@
+-- The placeholder realms and houses of the up migration are kept
+ALTER TABLE Auctions DROP CONSTRAINT IF EXISTS auctions_last_snapshot_fk;
+ALTER TABLE Auctions DROP CONSTRAINT IF EXISTS auctions_snapshot_fk;
+ALTER TABLE Auctions DROP CONSTRAINT IF EXISTS auctions_connected_realm_fk;
+DROP INDEX IF EXISTS auctions_connected_realm_id;
+ALTER TABLE Snapshots DROP CONSTRAINT IF EXISTS snapshots_connected_realm_fk;
+ALTER TABLE Snapshots DROP CONSTRAINT IF EXISTS snapshots_auction_house_fk;
@@ -0,0 +1,45 @@
+-- Snapshots belong to an auction house, rows of Auctions to a connected realm and their snapshots
+-- reset-realms used to delete realms and houses that still had snapshots and auctions, convert-auctions adds snapshots for the houses of Auctions,
+-- those get a row again before the keys are added: the connected realm without a name, the house disabled.
+INSERT INTO ConnectedRealms (connected_realm_id, game_version)
+    SELECT connected_realm_id, MIN(game_version) FROM (
+        SELECT connected_realm_id, game_version FROM Snapshots
+        UNION
+        SELECT connected_realm_id, game_version FROM Auctions WHERE connected_realm_id IS NOT NULL
+    ) R
+    GROUP BY connected_realm_id
+    ON CONFLICT (connected_realm_id) DO NOTHING;
+
+INSERT INTO AuctionHouses (connected_realm_id, auction_house_id, faction_id, enabled, game_version)
+    SELECT connected_realm_id, auction_house_id, MIN(faction_id), FALSE, MIN(game_version) FROM (
+        SELECT connected_realm_id, auction_house_id, faction_id, game_version FROM Snapshots
+        UNION
+        SELECT connected_realm_id, auction_house_id, faction_id, game_version FROM Auctions
+        WHERE connected_realm_id IS NOT NULL AND auction_house_id IS NOT NULL
+    ) H
+    GROUP BY connected_realm_id, auction_house_id
+    ON CONFLICT (connected_realm_id, auction_house_id) DO NOTHING;
+
+UPDATE Auctions SET snapshot_id = NULL
+    WHERE snapshot_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Snapshots S WHERE S.snapshot_id = Auctions.snapshot_id);
+
+UPDATE Auctions SET last_snapshot_id = NULL
+    WHERE last_snapshot_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Snapshots S WHERE S.snapshot_id = Auctions.last_snapshot_id);
+
+ALTER TABLE Snapshots ADD CONSTRAINT snapshots_auction_house_fk
+    FOREIGN KEY (connected_realm_id, auction_house_id) REFERENCES AuctionHouses(connected_realm_id, auction_house_id);
+
+ALTER TABLE Snapshots ADD CONSTRAINT snapshots_connected_realm_fk
+    FOREIGN KEY (connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id);
+
+-- Postgres does not index foreign keys, deleting a connected realm would scan Auctions
+CREATE INDEX IF NOT EXISTS auctions_connected_realm_id ON Auctions(connected_realm_id);
+
+ALTER TABLE Auctions ADD CONSTRAINT auctions_connected_realm_fk
+    FOREIGN KEY (connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id);
+
+ALTER TABLE Auctions ADD CONSTRAINT auctions_snapshot_fk
+    FOREIGN KEY (snapshot_id) REFERENCES Snapshots(snapshot_id);
+
+ALTER TABLE Auctions ADD CONSTRAINT auctions_last_snapshot_fk
+    FOREIGN KEY (last_snapshot_id) REFERENCES Snapshots(snapshot_id);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS Commodities;
+DROP TABLE IF EXISTS Auctions;
+DROP TABLE IF EXISTS Items;
+DROP TABLE IF EXISTS AuctionHouses;
+DROP TABLE IF EXISTS Factions;
+DROP TABLE IF EXISTS ConnectedRealmStatus;
+DROP TABLE IF EXISTS RealmHistory;
+DROP TABLE IF EXISTS Realms;
+DROP TABLE IF EXISTS ConnectedRealms;
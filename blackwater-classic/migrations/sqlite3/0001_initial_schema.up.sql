@@ -0,0 +1,121 @@
+-- Timestamps are unix times in seconds
+
+-- region := { EU = 0, US = 1, KR = 2, TW = 3, CN = 4 }
+-- game_version := { Era = 0, Progression Classic = 1, Retail = 2 }
+-- Blizzard numbers the connected realms of every region and game version from one pool, e.g. 5284 is only the Era realm in EU,
+-- so the ID alone is the key. update refuses a connected realm whose ID is stored for another region or game version.
+CREATE TABLE IF NOT EXISTS ConnectedRealms(
+    connected_realm_id INTEGER NOT NULL PRIMARY KEY,
+    region INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    name TEXT,
+    timezone TEXT);
+
+-- The realms that make up a connected realm
+-- A realm that is merged into another connected realm moves to it, see RealmHistory
+CREATE TABLE IF NOT EXISTS Realms(
+    realm_id INTEGER NOT NULL PRIMARY KEY,
+    connected_realm_id INTEGER NOT NULL,
+    region INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    name TEXT,
+    slug TEXT,
+    locale TEXT,
+    timezone TEXT,
+    category TEXT,
+    type TEXT,
+    is_tournament INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id));
+
+-- A row every time a realm is first seen or joins another connected realm
+CREATE TABLE IF NOT EXISTS RealmHistory(
+    realm_id INTEGER NOT NULL,
+    connected_realm_id INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+    PRIMARY KEY(realm_id, timestamp),
+    FOREIGN KEY(realm_id) REFERENCES Realms(realm_id));
+
+-- Status and population of a connected realm every time the realms are updated
+-- status := { UP, DOWN }
+-- population := { LOW, MEDIUM, HIGH, FULL, LOCKED, ... }
+CREATE TABLE IF NOT EXISTS ConnectedRealmStatus(
+    connected_realm_id INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+    status TEXT,
+    population TEXT,
+    has_queue INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(connected_realm_id, timestamp),
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id));
+
+CREATE TABLE IF NOT EXISTS Factions(
+    faction_id INTEGER PRIMARY KEY,
+    faction_name TEXT);
+
+-- Every auction house that a connected realm lists in its auction house index
+-- Classic: 2 = Alliance, 6 = Horde, 7 = Blackwater (neutral), see AuctionHouseFactions
+-- Retail: a single house with ID 0
+-- Auctions are only fetched from enabled houses, see the houses list in the servers files
+CREATE TABLE IF NOT EXISTS AuctionHouses(
+    connected_realm_id INTEGER NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER,
+    name TEXT,
+    href TEXT,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(connected_realm_id, auction_house_id),
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id));
+
+-- Item Class ID: Weapon, head, shoulder, etc
+-- Item Subclass ID: Stave, Sword etc.
+-- Example:
+-- Item Class ID: Consumable
+-- Item Subclass ID: Elixir, potion, enchant, flask, ....
+CREATE TABLE IF NOT EXISTS Items(
+    item_id INTEGER NOT NULL,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    item_class_id INTEGER,
+    item_class TEXT,
+    item_subclass_id INTEGER,
+    item_subclass TEXT,
+    quality TEXT,
+    name TEXT,
+    locale TEXT,
+    PRIMARY KEY(item_id, game_version));
+
+CREATE TABLE IF NOT EXISTS Auctions(
+    id INTEGER NOT NULL PRIMARY KEY,
+    auction_id INTEGER,
+    buyout INTEGER,
+    quantity INTEGER,
+    time_left TEXT,
+    timestamp DATETIME,
+    item_id INTEGER,
+    connected_realm_id INTEGER,
+    auction_house_id INTEGER,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY(connected_realm_id) REFERENCES ConnectedRealms(connected_realm_id),
+    FOREIGN KEY(item_id, game_version) REFERENCES Items(item_id, game_version),
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id),
+    UNIQUE(auction_id, connected_realm_id, time_left));
+
+-- Commodities are shared by every realm in a region and only exist on Retail
+-- Every snapshot of the market is stored with the same timestamp
+CREATE TABLE IF NOT EXISTS Commodities(
+    id INTEGER NOT NULL PRIMARY KEY,
+    auction_id INTEGER,
+    region INTEGER,
+    timestamp DATETIME,
+    item_id INTEGER,
+    quantity INTEGER,
+    unit_price INTEGER,
+    time_left TEXT,
+    UNIQUE(auction_id, region, timestamp));
+
+CREATE INDEX IF NOT EXISTS commodities_region_timestamp ON Commodities(region, timestamp);
+
+-- Indexed by faction ID, see Alliance, Horde and Neutral
+INSERT INTO Factions (faction_id, faction_name) VALUES (0, 'Alliance'), (1, 'Horde'), (2, 'Neutral')
+    ON CONFLICT (faction_id) DO NOTHING;
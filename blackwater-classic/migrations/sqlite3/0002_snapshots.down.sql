@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS auctions_snapshot_id;
+ALTER TABLE Auctions DROP COLUMN snapshot_id;
+DROP TABLE IF EXISTS Snapshots;
@@ -0,0 +1,24 @@
+-- One row per auction house dump we try to import, failed fetches included
+-- fetched_at is the import time of the run, every house fetched by the same run shares it.
+-- status := { running, complete, failed }, a snapshot that is still running after its run has ended was cut short
+CREATE TABLE IF NOT EXISTS Snapshots(
+    snapshot_id INTEGER NOT NULL PRIMARY KEY,
+    connected_realm_id INTEGER NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    fetched_at DATETIME NOT NULL,
+    last_modified DATETIME,
+    auction_count INTEGER NOT NULL DEFAULT 0,
+    byte_size INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT,
+    FOREIGN KEY(faction_id) REFERENCES Factions(faction_id));
+
+CREATE INDEX IF NOT EXISTS snapshots_house_fetched_at ON Snapshots(connected_realm_id, auction_house_id, fetched_at);
+
+-- The snapshot the auction was last seen in, NULL for auctions imported before snapshots existed
+ALTER TABLE Auctions ADD COLUMN snapshot_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS auctions_snapshot_id ON Auctions(snapshot_id);
@@ -0,0 +1,3 @@
+-- The Last-Modified time of the last dump we imported from the house
+-- It is sent as If-Modified-Since, so that an unchanged dump is not downloaded and imported again.
+ALTER TABLE AuctionHouses ADD COLUMN last_modified DATETIME;
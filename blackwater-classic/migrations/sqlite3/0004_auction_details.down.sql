@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS auctions_item_rand;
+UPDATE Auctions SET buyout = 0 WHERE buyout IS NULL;
+ALTER TABLE Auctions DROP COLUMN item_bonus_lists;
+ALTER TABLE Auctions DROP COLUMN item_modifiers;
+ALTER TABLE Auctions DROP COLUMN item_seed;
+ALTER TABLE Auctions DROP COLUMN item_rand;
+ALTER TABLE Auctions DROP COLUMN unit_price;
+ALTER TABLE Auctions DROP COLUMN bid;
@@ -0,0 +1,16 @@
+-- What the auction dumps tell besides the buyout
+-- bid and buyout are NULL when the auction has none, an auction without a buyout can only be bid on.
+-- unit_price is the price of a single item, the unit price of Retail stackables or the buyout divided by the quantity.
+-- item_rand is the random suffix of Classic items, e.g. "of the Monkey". item_modifiers and item_bonus_lists are JSON arrays.
+ALTER TABLE Auctions ADD COLUMN bid INTEGER;
+ALTER TABLE Auctions ADD COLUMN unit_price INTEGER;
+ALTER TABLE Auctions ADD COLUMN item_rand INTEGER;
+ALTER TABLE Auctions ADD COLUMN item_seed INTEGER;
+ALTER TABLE Auctions ADD COLUMN item_modifiers TEXT;
+ALTER TABLE Auctions ADD COLUMN item_bonus_lists TEXT;
+
+-- Auctions imported before this migration only have a buyout
+UPDATE Auctions SET buyout = NULL WHERE buyout = 0;
+UPDATE Auctions SET unit_price = (buyout + quantity / 2) / quantity WHERE buyout > 0 AND quantity > 0;
+
+CREATE INDEX IF NOT EXISTS auctions_item_rand ON Auctions(item_id, item_rand);
@@ -0,0 +1,27 @@
+-- One row per auction, from the first snapshot it was seen in to the first complete snapshot it was missing from
+-- ended_at and outcome are NULL while the auction is listed.
+-- outcome := { sold, expired, cancelled }, it is a guess from the time the auction had left when it was last seen
+CREATE TABLE IF NOT EXISTS AuctionLifecycles(
+    connected_realm_id INTEGER NOT NULL,
+    auction_id INTEGER NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    item_id INTEGER,
+    item_rand INTEGER,
+    quantity INTEGER,
+    bid INTEGER,
+    buyout INTEGER,
+    unit_price INTEGER,
+    first_seen DATETIME NOT NULL,
+    first_snapshot_id INTEGER NOT NULL,
+    first_time_left TEXT,
+    last_seen DATETIME NOT NULL,
+    last_snapshot_id INTEGER NOT NULL,
+    last_time_left TEXT,
+    ended_at DATETIME,
+    outcome TEXT,
+    PRIMARY KEY(connected_realm_id, auction_id));
+
+CREATE INDEX IF NOT EXISTS lifecycles_house_ended_at ON AuctionLifecycles(connected_realm_id, auction_house_id, ended_at);
+CREATE INDEX IF NOT EXISTS lifecycles_item_outcome ON AuctionLifecycles(item_id, outcome);
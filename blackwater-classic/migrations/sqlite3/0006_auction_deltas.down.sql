@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS PendingAuctions;
+DROP TABLE IF EXISTS AuctionChanges;
+
+ALTER TABLE AuctionLifecycles DROP COLUMN item_bonus_lists;
+ALTER TABLE AuctionLifecycles DROP COLUMN item_modifiers;
+ALTER TABLE AuctionLifecycles DROP COLUMN item_seed;
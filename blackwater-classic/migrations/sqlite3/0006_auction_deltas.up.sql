@@ -0,0 +1,44 @@
+-- Delta storage, see AuctionStorage
+-- An auction is written once to AuctionLifecycles, which now keeps everything about its item.
+-- AuctionChanges has a row for every snapshot that changed an auction: the one it appeared in,
+-- the ones where its time_left or bid changed and the one it was missing from, where time_left is NULL.
+ALTER TABLE AuctionLifecycles ADD COLUMN item_seed INTEGER;
+ALTER TABLE AuctionLifecycles ADD COLUMN item_modifiers TEXT;
+ALTER TABLE AuctionLifecycles ADD COLUMN item_bonus_lists TEXT;
+
+CREATE TABLE IF NOT EXISTS AuctionChanges(
+    connected_realm_id INTEGER NOT NULL,
+    auction_id INTEGER NOT NULL,
+    changed_at DATETIME NOT NULL,
+    auction_house_id INTEGER NOT NULL,
+    snapshot_id INTEGER NOT NULL,
+    time_left TEXT,
+    bid INTEGER,
+    PRIMARY KEY(connected_realm_id, auction_id, changed_at));
+
+CREATE INDEX IF NOT EXISTS auction_changes_house_changed_at ON AuctionChanges(connected_realm_id, auction_house_id, changed_at);
+
+-- The auctions of a snapshot are written here instead of Auctions when the storage is delta,
+-- and removed once its changes are recorded
+CREATE TABLE IF NOT EXISTS PendingAuctions(
+    id INTEGER NOT NULL PRIMARY KEY,
+    auction_id INTEGER,
+    bid INTEGER,
+    buyout INTEGER,
+    unit_price INTEGER,
+    quantity INTEGER,
+    time_left TEXT,
+    timestamp DATETIME,
+    item_id INTEGER,
+    item_rand INTEGER,
+    item_seed INTEGER,
+    item_modifiers TEXT,
+    item_bonus_lists TEXT,
+    connected_realm_id INTEGER,
+    auction_house_id INTEGER,
+    faction_id INTEGER,
+    game_version INTEGER NOT NULL DEFAULT 0,
+    snapshot_id INTEGER,
+    UNIQUE(auction_id, connected_realm_id, time_left));
+
+CREATE INDEX IF NOT EXISTS pending_auctions_snapshot_id ON PendingAuctions(snapshot_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS pending_auctions_last_snapshot_id;
+ALTER TABLE PendingAuctions DROP COLUMN last_snapshot_id;
+
+-- Before this migration snapshot_id was the last snapshot
+DROP INDEX IF EXISTS auctions_last_snapshot_id;
+UPDATE Auctions SET snapshot_id = last_snapshot_id WHERE last_snapshot_id IS NOT NULL;
+ALTER TABLE Auctions DROP COLUMN last_snapshot_id;
@@ -0,0 +1 @@
+-- The up migration has nothing to undo
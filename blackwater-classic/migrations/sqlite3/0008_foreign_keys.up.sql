@@ -0,0 +1,3 @@
+-- The postgres and mysql migrations add the foreign keys of Snapshots and Auctions here
+-- SQLite can't add a foreign key to an existing table, the tables would have to be copied.
+-- Foreign keys are not enforced on the SQLite connections anyway, so they would only document the schema.
@@ -0,0 +1,167 @@
+package blackwater
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Blizzard allows 100 requests per second and 36,000 requests per hour per client
+const (
+	DefaultRequestsPerSecond = 100
+	DefaultRequestsPerHour   = 36000
+)
+
+// Limits how fast requests can be sent
+// A request has to take a token from every bucket, so both the per-second and the per-hour budget are honored
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets []*tokenBucket
+}
+
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens added per second
+	last     time.Time
+}
+
+// Creates a limiter, a budget that is zero or less is not limited
+func NewRateLimiter(perSecond int, perHour int) *RateLimiter {
+	rl := &RateLimiter{}
+	now := time.Now()
+
+	if perSecond > 0 {
+		rl.buckets = append(rl.buckets, &tokenBucket{
+			capacity: float64(perSecond),
+			tokens:   float64(perSecond),
+			rate:     float64(perSecond),
+			last:     now,
+		})
+	}
+
+	if perHour > 0 {
+		rl.buckets = append(rl.buckets, &tokenBucket{
+			capacity: float64(perHour),
+			tokens:   float64(perHour),
+			rate:     float64(perHour) / time.Hour.Seconds(),
+			last:     now,
+		})
+	}
+
+	return rl
+}
+
+// Blocks until a request may be sent
+func (rl *RateLimiter) Wait() {
+	for {
+		wait := rl.reserve()
+
+		if wait == 0 {
+			return
+		}
+
+		time.Sleep(wait)
+	}
+}
+
+// Takes a token from every bucket if all of them have one,
+// otherwise returns how long to wait until they should
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+
+	for _, b := range rl.buckets {
+		b.refill(now)
+
+		if b.tokens < 1 {
+			missing := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+			if missing > wait {
+				wait = missing
+			}
+		}
+	}
+
+	if wait > 0 {
+		return wait
+	}
+
+	for _, b := range rl.buckets {
+		b.tokens--
+	}
+
+	return 0
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// Decides how often and how long to wait before a failed request is sent again
+// Requests are retried on 429, 5xx and network errors, with exponential backoff and full jitter.
+// A Retry-After header from the server takes precedence if it asks for a longer wait.
+type RetryPolicy struct {
+	MaxAttempts int // Including the first attempt, 1 disables retries
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+func retryableStatus(status int) bool {
+	return status == fasthttp.StatusTooManyRequests || status >= 500
+}
+
+// Returns how long to wait after the given attempt failed, attempt starts at 0
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := p.MaxDelay
+
+	if attempt < 32 && p.BaseDelay<<uint(attempt) < p.MaxDelay {
+		delay = p.BaseDelay << uint(attempt)
+	}
+
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	return delay
+}
+
+// Parses a Retry-After header, which is either in seconds or a HTTP date in any of the formats of RFC 9110
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,133 @@
+package blackwater
+
+import (
+	"blackwater/blackwater-classic/blackwatertest"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestAPI(t *testing.T, server *blackwatertest.Server, options APIOptions) *API {
+	t.Helper()
+
+	options.APIBaseURL = server.URL
+	options.OAuthURL = server.TokenURL()
+	options.TokenCachePath = filepath.Join(t.TempDir(), "blackwater.oauth")
+
+	api, err := NewAPIWithOptions(blackwatertest.ClientID, blackwatertest.ClientSecret, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return api
+}
+
+func TestRateLimiterPerSecond(t *testing.T) {
+	rl := NewRateLimiter(10, 0)
+
+	start := time.Now()
+
+	// The first 10 requests use up the burst, the next 5 have to wait 100ms each
+	for i := 0; i < 15; i++ {
+		rl.Wait()
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected the limiter to throttle, 15 requests took %s", elapsed)
+	}
+}
+
+func TestRateLimiterPerHour(t *testing.T) {
+	rl := NewRateLimiter(100, 3600)
+
+	// Drain the hourly budget, the next token arrives after a second
+	for _, b := range rl.buckets {
+		if b.capacity == 3600 {
+			b.tokens = 0
+		}
+	}
+
+	if wait := rl.reserve(); wait < 900*time.Millisecond || wait > time.Second {
+		t.Errorf("expected to wait about a second for the hourly budget, got %s", wait)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		if delay := policy.backoff(attempt, 0); delay > time.Second {
+			t.Errorf("attempt %d: delay %s is above the maximum", attempt, delay)
+		}
+	}
+
+	if delay := policy.backoff(0, 3*time.Second); delay != 3*time.Second {
+		t.Errorf("expected Retry-After to be honored, got %s", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("7"); d != 7*time.Second {
+		t.Errorf("expected 7s, got %s", d)
+	}
+
+	// IMF-fixdate, the obsolete RFC 850 and asctime formats
+	for _, layout := range []string{http.TimeFormat, "Monday, 02-Jan-06 15:04:05 GMT", time.ANSIC} {
+		date := time.Now().Add(time.Minute).UTC().Format(layout)
+		if d := parseRetryAfter(date); d < 55*time.Second || d > time.Minute {
+			t.Errorf("%s: expected about a minute, got %s", date, d)
+		}
+	}
+
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("expected 0 for an invalid header, got %s", d)
+	}
+}
+
+func TestAPIRetriesServerErrors(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{
+		Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	})
+
+	server.FailNext("/data/wow/item/13452", http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	if _, err := api.ClassicItem(13452); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := server.RequestCount("/data/wow/item/13452"); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+
+	// Give up once the attempts are used up
+	server.FailNext("/data/wow/item/13452", 500, 500, 500)
+
+	if _, err := api.ClassicItem(13452); err == nil {
+		t.Error("expected an error after running out of attempts")
+	}
+}
+
+func TestAPIHonorsRetryAfter(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	api := newTestAPI(t, server, APIOptions{
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	})
+
+	server.FailNext("/data/wow/item/13452", http.StatusTooManyRequests)
+
+	start := time.Now()
+
+	if _, err := api.ClassicItem(13452); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected to wait for Retry-After, retried after %s", elapsed)
+	}
+}
@@ -1,47 +1,118 @@
 package blackwater
 
 import (
-	"encoding/json"
+	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
 	"time"
+)
+
+// Faction that trades in each Classic auction house, keyed by the ID in the auction house index
+// Houses that are missing here, e.g. seasonal ones, are still stored, but without a faction
+var AuctionHouseFactions = map[int]int{
+	2: Alliance,
+	6: Horde,
+	7: Neutral, // Blackwater Auction House
+}
 
-	"github.com/valyala/fasthttp"
+const (
+	Alliance = iota
+	Horde
+	Neutral
 )
 
-func UpdateRealmTable(api *API, databaseFile string, server_json ServersJson) {
+// Names of the factions as used in the houses list of the servers files
+var FactionNames = [3]string{"alliance", "horde", "neutral"}
+
+// Retail has a single auction house that is shared by both factions,
+// it is stored with this ID
+const RetailAuctionHouseID = 0
+
+// Returns the auction houses of a connected realm
+// href is the auctions link of the connected realm
+func discoverAuctionHouses(api *API, connectedRealmID int, href string) ([]AuctionColumns, error) {
+
+	if api.gameVersion == Retail {
+		// The href points straight at the auctions
+		if len(href) == 0 {
+			return nil, ErrEmptyHref
+		}
+
+		return []AuctionColumns{{
+			ConnectedRealmID: connectedRealmID,
+			AuctionHouseID:   RetailAuctionHouseID,
+			AuctionHouseName: "Auction House",
+			FactionID:        sql.NullInt64{Int64: Neutral, Valid: true},
+			Href:             href,
+		}}, nil
+	}
+
+	// Fetch hrefs for all the auction houses for this realm
+	// These hrefs will be stored in the SQL DB and be the link
+	// that we use to fetch auction house data
+	ahMetadataJson, err := api.ClassicAuctionHouseIndexFromHref(href)
+
+	if err != nil {
+		return nil, err
+	}
+
+	houses := []AuctionColumns{}
+
+	for _, meta := range ahMetadataJson.Auctions {
+		house := AuctionColumns{
+			ConnectedRealmID: connectedRealmID,
+			AuctionHouseID:   meta.ID,
+			AuctionHouseName: meta.Name.In(api.locale),
+			Href:             meta.Key.Href,
+		}
+
+		if faction, ok := AuctionHouseFactions[meta.ID]; ok {
+			house.FactionID = sql.NullInt64{Int64: int64(faction), Valid: true}
+		} else {
+			log.Printf("Discovered auction house %d (%s) on connected realm %d, it has no known faction\n",
+				meta.ID, house.AuctionHouseName, connectedRealmID)
+		}
+
+		houses = append(houses, house)
+	}
+
+	return houses, nil
+}
+
+func UpdateRealmTable(api *API, db *Database, server_json ServersJson) {
 
-	db, err := OpenDB(databaseFile)
+	repo, err := NewRealmRepository(db.Handle, db.Dialect())
 
 	if err != nil {
-		log.Printf("Could not open DB: %q\n", err)
+		log.Printf("Could not prepare the realm statements: %q\n", err)
+		return
 	}
 
+	defer repo.Close()
+
+	updateTime := time.Now().Unix()
+
 	// Search for servers via the web API
 	// Collect their metadata and store it in the DB
 	for _, server := range server_json.Servers {
 
 		log.Printf("Server name: %s\n", server.Name)
 
-		time.Sleep(250 * time.Millisecond)
-
 		// Search for the realm using the search feature of connected realms
-		res, err := api.ConnectedRealmSearch(fmt.Sprintf("status.type=UP&realms.name.en_GB=%s", server.Name))
+		// Names are searched in the locale of the API, e.g. realms.name.de_DE=Everlook
+		// A + in the name stands for a space, as in Mirage+Raceway
+		name := url.QueryEscape(strings.ReplaceAll(server.Name, "+", " "))
+		searchJson, err := api.ConnectedRealmSearch(fmt.Sprintf("status.type=UP&realms.name.%s=%s", api.locale, name))
 
 		if err != nil {
 			log.Printf("Error searching for realm %s: %q\n", server.Name, err)
 			continue
 		}
 
-		defer fasthttp.ReleaseResponse(res)
-
-		var searchJson ConnectedRealmSearchJson
-		err = json.Unmarshal(res.Body(), &searchJson)
-
-		if err != nil {
-			log.Printf("Error unmarshaling response from connected-realm search: %q\n", err)
-		}
-
 		if len(searchJson.Results) > 0 {
 			ID := searchJson.Results[0].Data.ID
 			log.Println("ID:", ID)
@@ -49,67 +120,280 @@ func UpdateRealmTable(api *API, databaseFile string, server_json ServersJson) {
 			// Fetch data about the connected realm
 			// We will extract a href from this response that contains
 			// hrefs to each auction house
-			realmRes, err := api.ConnectedRealm(ID)
+			realmJson, err := api.ConnectedRealm(ID)
+
+			if IsNotFound(err) {
+				log.Printf("Connected realm %d no longer exists\n", ID)
+				disableConnectedRealm(repo, ID)
+				continue
+			}
 
 			if err != nil {
 				log.Printf("Error fetching connected realm ID %d: %q\n", ID, err)
 				continue
 			}
 
-			defer fasthttp.ReleaseResponse(realmRes)
+			houses, err := discoverAuctionHouses(api, ID, realmJson.Auctions.Href)
+
+			if err != nil {
+				log.Printf("Error fetching metadata from %d: %q\n", ID, err)
+				continue
+			}
+
+			for i := range houses {
+				houses[i].Enabled = houseSelected(server.Houses, houses[i])
+			}
 
-			var realmJson ConnectedRealmJsonLite
-			err = json.Unmarshal(realmRes.Body(), &realmJson)
+			err = repo.StoreConnectedRealm(api.region, api.gameVersion, realmJson, server.Name, houses, updateTime)
 
 			if err != nil {
-				log.Printf("Error unmarshaling response: %q\n", err)
+				log.Printf("Could not store connected realm %d: %q\n", ID, err)
 			}
+		}
+	}
+}
+
+// Reports whether house is selected by a houses list from the servers files
+// The list holds faction names or auction house IDs, e.g. ["alliance", "horde", "9"]
+// An empty list selects every house
+func houseSelected(houses []string, house AuctionColumns) bool {
+	if len(houses) == 0 {
+		return true
+	}
 
-			href := realmJson.Auctions.Href
+	for _, selected := range houses {
+		if selected == strconv.Itoa(house.AuctionHouseID) {
+			return true
+		}
 
-			if len(href) > 0 {
+		if house.FactionID.Valid && strings.EqualFold(selected, FactionNames[house.FactionID.Int64]) {
+			return true
+		}
+	}
 
-				// Fetch hrefs for all the three auction houses for this realm
-				// These hrefs will be stored in the SQL DB and be the link
-				// that we use to fetch auction house data
-				metadataRes, err := api.FetchFromHref(href)
+	return false
+}
 
-				if err != nil {
-					log.Printf("Error fetching metadata from %d: %q\n", ID, err)
-					continue
-				}
+// Decides which connected realms are tracked when every realm of a region is discovered
+// A connected realm is tracked if one of its realms is included and none of them is excluded
+type RealmFilter struct {
+	Include    []string // Realm names or slugs, empty includes every realm
+	Exclude    []string // Realm names or slugs
+	Timezones  []string // e.g. Europe/Paris, empty includes every timezone
+	Tournament bool     // Include tournament realms
+	Houses     []string // Auction houses to fetch auctions from, see houseSelected
+}
 
-				defer fasthttp.ReleaseResponse(metadataRes)
+func (f RealmFilter) Match(realm *ConnectedRealmJson) bool {
+	included := false
 
-				var ahMetadataJson AuctionHouseMetaDataJson
-				err = json.Unmarshal(metadataRes.Body(), &ahMetadataJson)
+	for _, r := range realm.Realms {
+		if containsFold(f.Exclude, r.Name) || containsFold(f.Exclude, r.Slug) {
+			return false
+		}
 
-				if err != nil {
-					log.Printf("Error unmarshaling response: %q\n", err)
-				}
+		if r.IsTournament && !f.Tournament {
+			continue
+		}
 
-				var alliance_href, horde_href, neutral_href string
+		if len(f.Timezones) > 0 && !containsFold(f.Timezones, r.Timezone) {
+			continue
+		}
 
-				for _, meta := range ahMetadataJson.Auctions {
-					log.Printf("meta.Key.Href = %s\n", meta.Key.Href)
-					if meta.Name.En_GB == "Alliance Auction House" {
-						alliance_href = meta.Key.Href
-					} else if meta.Name.En_GB == "Horde Auction House" {
-						horde_href = meta.Key.Href
-					} else if meta.Name.En_GB == "Blackwater Auction House" {
-						neutral_href = meta.Key.Href
-					}
-				}
+		if len(f.Include) == 0 || containsFold(f.Include, r.Name) || containsFold(f.Include, r.Slug) {
+			included = true
+		}
+	}
 
-				_, err = db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO connectedRealms (connected_realm_id, region, name, alliance_ah_href, horde_ah_href, neutral_ah_href) VALUES ('%d', '%d', '%s', '%s', '%s', '%s')",
-					ID, api.region, server.Name, alliance_href, horde_href, neutral_href))
+	return included
+}
 
-				if err != nil {
-					log.Printf("Could not insert/replace into the connectedRealms table: %q\n", err)
-				}
-			}
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Walks the connected realm index of the current region and stores every connected realm that matches filter
+// The connected realm is named after its realms, e.g. Mirage Raceway+Golemagg
+func DiscoverRealms(api *API, db *Database, filter RealmFilter) error {
+
+	repo, err := NewRealmRepository(db.Handle, db.Dialect())
+
+	if err != nil {
+		return err
+	}
+
+	defer repo.Close()
+
+	index, err := api.ConnectedRealmsIndex()
+
+	if err != nil {
+		return err
+	}
+
+	stored := 0
+	updateTime := time.Now().Unix()
+
+	// Every connected realm of the index, also the ones the filter leaves out
+	listed := map[int]bool{}
+
+	for _, connectedRealm := range index.ConnectedRealms {
+		ID, err := connectedRealmIDFromHref(connectedRealm.Href)
+
+		if err != nil {
+			log.Printf("Skipping connected realm %s: %q\n", connectedRealm.Href, err)
+			continue
 		}
+
+		// Fetched by ID rather than href, so that names are returned in the locale of the API
+		listed[ID] = true
+
+		realmJson, err := api.ConnectedRealm(ID)
+
+		if IsNotFound(err) {
+			log.Printf("Connected realm %d no longer exists\n", ID)
+			disableConnectedRealm(repo, ID)
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Error fetching connected realm ID %d: %q\n", ID, err)
+			continue
+		}
+
+		if !filter.Match(realmJson) {
+			continue
+		}
+
+		names := []string{}
+		for _, realm := range realmJson.Realms {
+			names = append(names, realm.Name)
+		}
+
+		houses, err := discoverAuctionHouses(api, ID, realmJson.Auctions.Href)
+
+		if err != nil {
+			log.Printf("Error fetching metadata from %d: %q\n", ID, err)
+			continue
+		}
+
+		for i := range houses {
+			houses[i].Enabled = houseSelected(filter.Houses, houses[i])
+		}
+
+		err = repo.StoreConnectedRealm(api.region, api.gameVersion, realmJson, strings.Join(names, "+"), houses, updateTime)
+
+		if err != nil {
+			log.Printf("Could not store connected realm %d: %q\n", ID, err)
+			continue
+		}
+
+		stored++
+	}
+
+	log.Printf("Discovered %d of %d connected realms in %s\n", stored, len(index.ConnectedRealms), api.region)
+
+	return repo.DisableUnlistedConnectedRealms(api.region, api.gameVersion, listed)
+}
+
+func disableConnectedRealm(repo *RealmRepository, connectedRealmID int) {
+	err := repo.DisableConnectedRealm(connectedRealmID)
+
+	if err != nil {
+		log.Printf("Could not disable the auction houses of connected realm %d: %q\n", connectedRealmID, err)
+	}
+}
+
+// Returns 4467 for e.g. https://eu.api.blizzard.com/data/wow/connected-realm/4467?namespace=dynamic-classic1x-eu
+func connectedRealmIDFromHref(href string) (int, error) {
+	u, err := url.Parse(href)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(path.Base(u.Path))
+}
+
+// Deletes the realms, and the auction houses and connected realms nothing refers to, so that update starts over
+// Houses with snapshots and connected realms with auctions are kept for them, the houses are disabled until update finds them again.
+// The status and membership history of the realms is kept.
+func ResetRealms(db *Database) error {
+	dialect := db.Dialect()
+
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM Realms`)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM AuctionHouses
+		WHERE NOT EXISTS (SELECT 1 FROM Snapshots S
+			WHERE S.connected_realm_id = AuctionHouses.connected_realm_id AND S.auction_house_id = AuctionHouses.auction_house_id)`)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(dialect.Rebind(`UPDATE AuctionHouses SET enabled = ?`), false)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM ConnectedRealms
+		WHERE NOT EXISTS (SELECT 1 FROM AuctionHouses H WHERE H.connected_realm_id = ConnectedRealms.connected_realm_id)
+		AND NOT EXISTS (SELECT 1 FROM Auctions A WHERE A.connected_realm_id = ConnectedRealms.connected_realm_id)`)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Stores the connected realm and the auction house of house unless they exist, e.g. before replaying its dumps into a new database
+// Rows that exist are left as they are. A house that is added has no href and stays disabled until update finds it.
+func EnsureAuctionHouse(db *Database, house AuctionColumns) error {
+	dialect := db.Dialect()
+
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(dialect.UpsertUpdating("ConnectedRealms",
+		[]string{"connected_realm_id", "region", "game_version", "name"}, []string{}, []string{"connected_realm_id"}),
+		house.ConnectedRealmID, house.Region, house.GameVersion, house.Name)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(dialect.UpsertUpdating("AuctionHouses",
+		[]string{"connected_realm_id", "auction_house_id", "faction_id", "enabled", "game_version"}, []string{},
+		[]string{"connected_realm_id", "auction_house_id"}),
+		house.ConnectedRealmID, house.AuctionHouseID, house.FactionID, false, house.GameVersion)
+
+	if err != nil {
+		return err
 	}
 
-	db.Close()
+	return tx.Commit()
 }
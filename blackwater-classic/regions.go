@@ -0,0 +1,139 @@
+package blackwater
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	EU Region = iota
+	US
+	KR
+	TW
+	CN
+)
+
+const (
+	EnUS Locale = "en_US"
+	EsMX Locale = "es_MX"
+	PtBR Locale = "pt_BR"
+	EnGB Locale = "en_GB"
+	EsES Locale = "es_ES"
+	FrFR Locale = "fr_FR"
+	RuRU Locale = "ru_RU"
+	DeDE Locale = "de_DE"
+	PtPT Locale = "pt_PT"
+	ItIT Locale = "it_IT"
+	KoKR Locale = "ko_KR"
+	ZhTW Locale = "zh_TW"
+	ZhCN Locale = "zh_CN"
+)
+
+// Everything we need to know to talk to a Battle.net region
+type RegionInfo struct {
+	Code          string // Used in hosts and namespaces, e.g. "eu"
+	APIBaseURL    string
+	OAuthURL      string
+	DefaultLocale Locale
+	Locales       []Locale // Locales the region serves names in
+}
+
+// Every region we support, indexed by Region
+// China has its own hosts and needs its own credentials
+var Regions = []RegionInfo{
+	EU: {
+		Code:          "eu",
+		APIBaseURL:    "https://eu.api.blizzard.com",
+		OAuthURL:      DefaultOAuthURL,
+		DefaultLocale: EnGB,
+		Locales:       []Locale{EnGB, DeDE, FrFR, EsES, RuRU, PtPT, ItIT},
+	},
+	US: {
+		Code:          "us",
+		APIBaseURL:    "https://us.api.blizzard.com",
+		OAuthURL:      DefaultOAuthURL,
+		DefaultLocale: EnUS,
+		Locales:       []Locale{EnUS, EsMX, PtBR},
+	},
+	KR: {
+		Code:          "kr",
+		APIBaseURL:    "https://kr.api.blizzard.com",
+		OAuthURL:      DefaultOAuthURL,
+		DefaultLocale: KoKR,
+		Locales:       []Locale{KoKR, EnUS},
+	},
+	TW: {
+		Code:          "tw",
+		APIBaseURL:    "https://tw.api.blizzard.com",
+		OAuthURL:      DefaultOAuthURL,
+		DefaultLocale: ZhTW,
+		Locales:       []Locale{ZhTW, EnUS},
+	},
+	CN: {
+		Code:          "cn",
+		APIBaseURL:    "https://gateway.battlenet.com.cn",
+		OAuthURL:      "https://oauth.battlenet.com.cn/token",
+		DefaultLocale: ZhCN,
+		Locales:       []Locale{ZhCN, EnUS},
+	},
+}
+
+var AllRegions = []Region{EU, US, KR, TW, CN}
+
+func (r Region) Info() RegionInfo {
+	if r < 0 || int(r) >= len(Regions) {
+		return RegionInfo{}
+	}
+
+	return Regions[r]
+}
+
+func (r Region) String() string {
+	if info := r.Info(); info.Code != "" {
+		return info.Code
+	}
+
+	return fmt.Sprintf("Region(%d)", int(r))
+}
+
+// Returns e.g. dynamic-classic1x-eu, static-classic-kr or dynamic-us
+// kind is either "dynamic" or "static"
+func (r Region) Namespace(kind string, gameVersion GameVersion) string {
+	return kind + namespaceVersions[gameVersion] + "-" + r.String()
+}
+
+func (r Region) SupportsLocale(locale Locale) bool {
+	for _, l := range r.Info().Locales {
+		if l == locale {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parses a region code such as "eu" or "KR"
+func ParseRegion(code string) (Region, error) {
+	for _, region := range AllRegions {
+		if strings.EqualFold(region.String(), code) {
+			return region, nil
+		}
+	}
+
+	return EU, fmt.Errorf("unknown region %q", code)
+}
+
+// Parses a locale written as de_DE, de-DE or deDE, the last one is how realms report their locale
+func ParseLocale(s string) (Locale, error) {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(s)
+
+	for _, region := range AllRegions {
+		for _, locale := range region.Info().Locales {
+			if strings.EqualFold(strings.ReplaceAll(string(locale), "_", ""), normalized) {
+				return locale, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unknown locale %q", s)
+}
@@ -0,0 +1,80 @@
+package blackwater
+
+import (
+	"testing"
+)
+
+func TestRegionHostsAndNamespaces(t *testing.T) {
+	api := &API{tokens: make(map[string]*TokenSource), tokenCachePath: DefaultTokenCachePath}
+
+	expected := []struct {
+		region    Region
+		host      string
+		namespace string
+		oauthURL  string
+		cachePath string
+	}{
+		{EU, "https://eu.api.blizzard.com", "dynamic-classic1x-eu", DefaultOAuthURL, "blackwater.oauth"},
+		{US, "https://us.api.blizzard.com", "dynamic-classic1x-us", DefaultOAuthURL, "blackwater.oauth"},
+		{KR, "https://kr.api.blizzard.com", "dynamic-classic1x-kr", DefaultOAuthURL, "blackwater.oauth"},
+		{TW, "https://tw.api.blizzard.com", "dynamic-classic1x-tw", DefaultOAuthURL, "blackwater.oauth"},
+		{CN, "https://gateway.battlenet.com.cn", "dynamic-classic1x-cn", "https://oauth.battlenet.com.cn/token", "blackwater.oauth.cn"},
+	}
+
+	for _, e := range expected {
+		api.SetRegion(e.region, "")
+
+		if host := api.baseURL(); host != e.host {
+			t.Errorf("%s: expected host %s, got %s", e.region, e.host, host)
+		}
+
+		if ns := api.namespace("dynamic"); ns != e.namespace {
+			t.Errorf("%s: expected namespace %s, got %s", e.region, e.namespace, ns)
+		}
+
+		if tokens := api.Tokens(); tokens.TokenURL != e.oauthURL || tokens.CachePath != e.cachePath {
+			t.Errorf("%s: unexpected token source %s %s", e.region, tokens.TokenURL, tokens.CachePath)
+		}
+
+		if api.Locale() != e.region.Info().DefaultLocale {
+			t.Errorf("%s: expected the default locale, got %s", e.region, api.Locale())
+		}
+	}
+
+	// Every region except China shares one token
+	if len(api.tokens) != 2 {
+		t.Errorf("expected 2 token sources, got %d", len(api.tokens))
+	}
+
+	// An overridden host applies to every region
+	api.apiBaseURL = "http://localhost:8080/{region}"
+	api.SetRegion(KR, KoKR)
+
+	if host := api.baseURL(); host != "http://localhost:8080/kr" {
+		t.Errorf("expected the overridden host, got %s", host)
+	}
+}
+
+func TestParseRegionAndLocale(t *testing.T) {
+	if region, err := ParseRegion("TW"); err != nil || region != TW {
+		t.Errorf("expected TW, got %v %v", region, err)
+	}
+
+	if _, err := ParseRegion("atlantis"); err == nil {
+		t.Error("expected an error for an unknown region")
+	}
+
+	for _, s := range []string{"fr_FR", "frFR", "fr-fr"} {
+		if locale, err := ParseLocale(s); err != nil || locale != FrFR {
+			t.Errorf("%s: expected fr_FR, got %v %v", s, locale, err)
+		}
+	}
+
+	if _, err := ParseLocale("xx_XX"); err == nil {
+		t.Error("expected an error for an unknown locale")
+	}
+
+	if !EU.SupportsLocale(DeDE) || US.SupportsLocale(DeDE) {
+		t.Error("de_DE should only be served in EU")
+	}
+}
@@ -0,0 +1,270 @@
+package blackwater
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Persists connected realms, their realms, their status history and their auction houses
+// Every statement is prepared once, values are never formatted into the SQL.
+// Auction houses that the API no longer lists are disabled, they keep their snapshots.
+type RealmRepository struct {
+	db      *sql.DB
+	dialect Dialect
+
+	upsertConnectedRealm *sql.Stmt
+	selectConnectedRealm *sql.Stmt
+	upsertRealm          *sql.Stmt
+	selectRealm          *sql.Stmt
+	insertRealmHistory   *sql.Stmt
+	upsertStatus         *sql.Stmt
+	upsertAuctionHouse   *sql.Stmt
+	selectAuctionHouses  *sql.Stmt
+	disableAuctionHouse  *sql.Stmt
+	disableRealmHouses   *sql.Stmt
+	selectRegionRealms   *sql.Stmt
+}
+
+func NewRealmRepository(db *sql.DB, dialect Dialect) (*RealmRepository, error) {
+	repo := &RealmRepository{db: db, dialect: dialect}
+
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&repo.upsertConnectedRealm, dialect.Upsert("ConnectedRealms",
+			[]string{"connected_realm_id", "region", "game_version", "name", "timezone"},
+			[]string{"connected_realm_id"})},
+		{&repo.selectConnectedRealm, dialect.Rebind(`SELECT region, game_version FROM ConnectedRealms WHERE connected_realm_id = ?`)},
+		{&repo.upsertRealm, dialect.Upsert("Realms",
+			[]string{"realm_id", "connected_realm_id", "region", "game_version", "name", "slug", "locale", "timezone", "category", "type", "is_tournament"},
+			[]string{"realm_id"})},
+		{&repo.selectRealm, dialect.Rebind(`SELECT connected_realm_id FROM Realms WHERE realm_id = ?`)},
+		{&repo.insertRealmHistory, dialect.Upsert("RealmHistory",
+			[]string{"realm_id", "connected_realm_id", "timestamp"},
+			[]string{"realm_id", "timestamp"})},
+		{&repo.upsertStatus, dialect.Upsert("ConnectedRealmStatus",
+			[]string{"connected_realm_id", "timestamp", "status", "population", "has_queue"},
+			[]string{"connected_realm_id", "timestamp"})},
+		{&repo.upsertAuctionHouse, dialect.Upsert("AuctionHouses",
+			[]string{"connected_realm_id", "auction_house_id", "faction_id", "name", "href", "enabled", "game_version"},
+			[]string{"connected_realm_id", "auction_house_id"})},
+		{&repo.selectAuctionHouses, dialect.Rebind(`SELECT auction_house_id FROM AuctionHouses WHERE connected_realm_id = ? AND enabled = ?`)},
+		{&repo.disableAuctionHouse, dialect.Rebind(`UPDATE AuctionHouses SET enabled = ? WHERE connected_realm_id = ? AND auction_house_id = ?`)},
+		{&repo.disableRealmHouses, dialect.Rebind(`UPDATE AuctionHouses SET enabled = ? WHERE connected_realm_id = ?`)},
+		{&repo.selectRegionRealms, dialect.Rebind(`SELECT connected_realm_id FROM ConnectedRealms WHERE region = ? AND game_version = ?`)},
+	}
+
+	for _, s := range statements {
+		stmt, err := db.Prepare(s.query)
+
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+
+		*s.stmt = stmt
+	}
+
+	return repo, nil
+}
+
+func (repo *RealmRepository) Close() error {
+	for _, stmt := range []*sql.Stmt{
+		repo.upsertConnectedRealm,
+		repo.selectConnectedRealm,
+		repo.upsertRealm,
+		repo.selectRealm,
+		repo.insertRealmHistory,
+		repo.upsertStatus,
+		repo.upsertAuctionHouse,
+		repo.selectAuctionHouses,
+		repo.disableAuctionHouse,
+		repo.disableRealmHouses,
+		repo.selectRegionRealms,
+	} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+
+	return nil
+}
+
+// Stores a connected realm, its realms, its current status and its auction houses in one transaction
+// updateTime is the time of the status row and of realms joining the connected realm
+// houses are all the auction houses the connected realm lists, the stored houses that are missing from it are disabled.
+func (repo *RealmRepository) StoreConnectedRealm(region Region, gameVersion GameVersion, realmJson *ConnectedRealmJson, name string, houses []AuctionColumns, updateTime int64) error {
+	tx, err := repo.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = repo.storeConnectedRealm(tx, region, gameVersion, realmJson, name, houses, updateTime)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *RealmRepository) storeConnectedRealm(tx *sql.Tx, region Region, gameVersion GameVersion, realmJson *ConnectedRealmJson, name string, houses []AuctionColumns, updateTime int64) error {
+	timezone := ""
+	if len(realmJson.Realms) > 0 {
+		timezone = realmJson.Realms[0].Timezone
+	}
+
+	// Connected realms are only keyed by their ID, it must not be taken by a realm of another region or game version
+	// Rows without a region come from snapshots that were stored before the realm.
+	var storedRegion sql.NullInt64
+	var storedGameVersion GameVersion
+	err := tx.Stmt(repo.selectConnectedRealm).QueryRow(realmJson.ID).Scan(&storedRegion, &storedGameVersion)
+
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil && (storedGameVersion != gameVersion || storedRegion.Valid && Region(storedRegion.Int64) != region) {
+		return fmt.Errorf("connected realm %d is stored for %s in %s, not %s in %s",
+			realmJson.ID, storedGameVersion, Region(storedRegion.Int64), gameVersion, region)
+	}
+
+	_, err = tx.Stmt(repo.upsertConnectedRealm).Exec(realmJson.ID, region, gameVersion, name, timezone)
+
+	if err != nil {
+		return err
+	}
+
+	for _, realm := range realmJson.Realms {
+		var previousID int
+		err = tx.Stmt(repo.selectRealm).QueryRow(realm.ID).Scan(&previousID)
+
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		joined := err == sql.ErrNoRows || previousID != realmJson.ID
+
+		_, err = tx.Stmt(repo.upsertRealm).Exec(
+			realm.ID, realmJson.ID, region, gameVersion,
+			realm.Name, realm.Slug, realm.Locale, realm.Timezone, realm.Category, realm.Type.Type, realm.IsTournament)
+
+		if err != nil {
+			return err
+		}
+
+		// Only the first time we see the realm or when it has been merged into another connected realm
+		if joined {
+			_, err = tx.Stmt(repo.insertRealmHistory).Exec(realm.ID, realmJson.ID, repo.dialect.timestamp(updateTime))
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Stmt(repo.upsertStatus).Exec(realmJson.ID, repo.dialect.timestamp(updateTime), realmJson.Status.Type, realmJson.Population.Type, realmJson.HasQueue)
+
+	if err != nil {
+		return err
+	}
+
+	listed := map[int]bool{}
+
+	for _, house := range houses {
+		_, err = tx.Stmt(repo.upsertAuctionHouse).Exec(
+			realmJson.ID, house.AuctionHouseID, house.FactionID, house.AuctionHouseName, house.Href, house.Enabled, gameVersion)
+
+		if err != nil {
+			return err
+		}
+
+		listed[house.AuctionHouseID] = true
+	}
+
+	enabled, err := queryIDs(tx.Stmt(repo.selectAuctionHouses), realmJson.ID, true)
+
+	if err != nil {
+		return err
+	}
+
+	for _, auctionHouseID := range enabled {
+		if listed[auctionHouseID] {
+			continue
+		}
+
+		log.Printf("Auction house %d of connected realm %d is no longer listed, disabling it\n", auctionHouseID, realmJson.ID)
+
+		_, err = tx.Stmt(repo.disableAuctionHouse).Exec(false, realmJson.ID, auctionHouseID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Disables the auction houses of a connected realm that no longer exists
+func (repo *RealmRepository) DisableConnectedRealm(connectedRealmID int) error {
+	_, err := repo.disableRealmHouses.Exec(false, connectedRealmID)
+
+	return err
+}
+
+// Disables the auction houses of the connected realms of region and gameVersion that are not in listed
+// listed holds the IDs of a complete connected realm index, connected realms that are missing from it no longer exist.
+func (repo *RealmRepository) DisableUnlistedConnectedRealms(region Region, gameVersion GameVersion, listed map[int]bool) error {
+	stored, err := queryIDs(repo.selectRegionRealms, region, gameVersion)
+
+	if err != nil {
+		return err
+	}
+
+	for _, connectedRealmID := range stored {
+		if listed[connectedRealmID] {
+			continue
+		}
+
+		log.Printf("Connected realm %d is no longer listed in %s, disabling its auction houses\n", connectedRealmID, region)
+
+		err = repo.DisableConnectedRealm(connectedRealmID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reads the IDs a statement returns, the rows are closed before anything else is run
+func queryIDs(stmt *sql.Stmt, args ...interface{}) ([]int, error) {
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+
+		err = rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
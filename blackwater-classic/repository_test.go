@@ -0,0 +1,274 @@
+package blackwater
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database := NewLocalDatabase(filepath.Join(t.TempDir(), "blackwater.db"))
+
+	if err := SetupDatabase(&database); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(database.CloseConnection)
+
+	return database.Handle
+}
+
+func testConnectedRealm(id int, population string, realmIDs ...int) *ConnectedRealmJson {
+	realm := &ConnectedRealmJson{ID: id}
+	realm.Status.Type = "UP"
+	realm.Population.Type = population
+
+	for _, realmID := range realmIDs {
+		realm.Realms = append(realm.Realms, RealmJson{ID: realmID, Timezone: "Europe/Paris"})
+	}
+
+	return realm
+}
+
+func newTestRepository(t *testing.T, db *sql.DB) *RealmRepository {
+	t.Helper()
+
+	repo, err := NewRealmRepository(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestRealmHistory(t *testing.T) {
+	db := openTestDB(t)
+	repo := newTestRepository(t, db)
+
+	// Two connected realms, of which the second is merged into the first a day later
+	steps := []struct {
+		time   int64
+		realms []*ConnectedRealmJson
+	}{
+		{1000, []*ConnectedRealmJson{testConnectedRealm(100, "LOW", 1), testConnectedRealm(101, "LOW", 2)}},
+		{2000, []*ConnectedRealmJson{testConnectedRealm(100, "LOW", 1), testConnectedRealm(101, "MEDIUM", 2)}},
+		{86400, []*ConnectedRealmJson{testConnectedRealm(100, "HIGH", 1, 2)}},
+	}
+
+	for _, step := range steps {
+		for _, realm := range step.realms {
+			if err := repo.StoreConnectedRealm(EU, Era, realm, "", nil, step.time); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var connectedRealmID int
+	if err := db.QueryRow(`SELECT connected_realm_id FROM Realms WHERE realm_id = 2`).Scan(&connectedRealmID); err != nil {
+		t.Fatal(err)
+	}
+
+	if connectedRealmID != 100 {
+		t.Errorf("expected realm 2 to be part of 100, got %d", connectedRealmID)
+	}
+
+	rows, err := db.Query(`SELECT connected_realm_id, CAST(timestamp AS INTEGER) FROM RealmHistory WHERE realm_id = 2 ORDER BY timestamp`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	history := [][2]int64{}
+	for rows.Next() {
+		var entry [2]int64
+		if err := rows.Scan(&entry[0], &entry[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		history = append(history, entry)
+	}
+
+	if len(history) != 2 || history[0] != [2]int64{101, 1000} || history[1] != [2]int64{100, 86400} {
+		t.Errorf("unexpected history of realm 2: %v", history)
+	}
+
+	var statusRows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ConnectedRealmStatus WHERE connected_realm_id = 101`).Scan(&statusRows); err != nil {
+		t.Fatal(err)
+	}
+
+	if statusRows != 2 {
+		t.Errorf("expected 2 status rows for 101, got %d", statusRows)
+	}
+
+	var population string
+	if err := db.QueryRow(`SELECT population FROM ConnectedRealmStatus WHERE connected_realm_id = 100 AND timestamp = 86400`).Scan(&population); err != nil {
+		t.Fatal(err)
+	}
+
+	if population != "HIGH" {
+		t.Errorf("expected population HIGH after the merge, got %q", population)
+	}
+}
+
+func TestQuotedRealmNames(t *testing.T) {
+	db := openTestDB(t)
+	repo := newTestRepository(t, db)
+
+	names := []string{
+		"Zul'jin",
+		"Mal'Ganis+Kil'jaeden",
+		`Robert'); DROP TABLE Realms; --`,
+		`"Quoted" \ Realm`,
+	}
+
+	for i, name := range names {
+		realm := testConnectedRealm(200+i, "LOW", 200+i)
+		realm.Realms[0].Name = name
+
+		house := AuctionColumns{AuctionHouseID: 2, AuctionHouseName: "L'Hôtel des ventes", Enabled: true}
+
+		if err := repo.StoreConnectedRealm(US, Era, realm, name, []AuctionColumns{house}, 1000); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Storing a realm again updates it in place
+		if err := repo.StoreConnectedRealm(US, Era, realm, name, []AuctionColumns{house}, 2000); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	for i, name := range names {
+		var connectedRealmName, realmName, houseName string
+
+		err := db.QueryRow(`SELECT C.name, R.name, A.name
+			FROM ConnectedRealms C
+			JOIN Realms R ON R.connected_realm_id = C.connected_realm_id
+			JOIN AuctionHouses A ON A.connected_realm_id = C.connected_realm_id
+			WHERE C.connected_realm_id = ?`, 200+i).Scan(&connectedRealmName, &realmName, &houseName)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if connectedRealmName != name || realmName != name || houseName != "L'Hôtel des ventes" {
+			t.Errorf("expected %q, got %q, %q and %q", name, connectedRealmName, realmName, houseName)
+		}
+	}
+
+	var realms int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Realms`).Scan(&realms); err != nil {
+		t.Fatal(err)
+	}
+
+	if realms != len(names) {
+		t.Errorf("expected %d realms, got %d", len(names), realms)
+	}
+}
+
+func TestConnectedRealmIDsAreUnique(t *testing.T) {
+	db := openTestDB(t)
+	repo := newTestRepository(t, db)
+
+	if err := repo.StoreConnectedRealm(EU, Era, testConnectedRealm(300, "LOW", 1), "Era", nil, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ID of another region or game version is refused instead of overwriting the realm
+	for _, other := range []struct {
+		region      Region
+		gameVersion GameVersion
+	}{{US, Era}, {EU, Retail}} {
+		if err := repo.StoreConnectedRealm(other.region, other.gameVersion, testConnectedRealm(300, "LOW", 1), "Other", nil, 2000); err == nil {
+			t.Errorf("expected an error for connected realm 300 in %s %s", other.region, other.gameVersion)
+		}
+	}
+
+	var name string
+	if err := db.QueryRow(`SELECT name FROM ConnectedRealms WHERE connected_realm_id = 300`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "Era" {
+		t.Errorf("expected connected realm 300 to be kept, got %q", name)
+	}
+
+	// A placeholder without a region, as 0008_foreign_keys adds them, is taken over by its realm
+	if _, err := db.Exec(`INSERT INTO ConnectedRealms (connected_realm_id, game_version) VALUES (301, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.StoreConnectedRealm(US, Era, testConnectedRealm(301, "LOW", 2), "Placeholder", nil, 1000); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnlistedAuctionHousesAreDisabled(t *testing.T) {
+	db := openTestDB(t)
+	repo := newTestRepository(t, db)
+
+	houses := []AuctionColumns{
+		{AuctionHouseID: 2, Enabled: true},
+		{AuctionHouseID: 6, Enabled: true},
+		{AuctionHouseID: 9, Enabled: true},
+	}
+
+	for _, id := range []int{400, 401} {
+		if err := repo.StoreConnectedRealm(EU, Era, testConnectedRealm(id, "LOW", id), "", houses, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The seasonal house is gone from 400
+	if err := repo.StoreConnectedRealm(EU, Era, testConnectedRealm(400, "LOW", 400), "", houses[:2], 2000); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := func(connectedRealmID int) int {
+		t.Helper()
+
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM AuctionHouses WHERE connected_realm_id = ? AND enabled`, connectedRealmID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	if n := enabled(400); n != 2 {
+		t.Errorf("expected 2 enabled houses on 400, got %d", n)
+	}
+
+	// 401 is missing from the index, other regions and game versions are left alone
+	if err := repo.DisableUnlistedConnectedRealms(US, Era, map[int]bool{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DisableUnlistedConnectedRealms(EU, Era, map[int]bool{400: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if enabled(400) != 2 || enabled(401) != 0 {
+		t.Errorf("expected only the houses of 401 to be disabled, got %d and %d enabled", enabled(400), enabled(401))
+	}
+
+	var houseCount int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM AuctionHouses`).Scan(&houseCount); err != nil {
+		t.Fatal(err)
+	}
+
+	if houseCount != 6 {
+		t.Errorf("expected the disabled houses to be kept, got %d houses", houseCount)
+	}
+}
@@ -0,0 +1,149 @@
+package blackwater
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The status of a snapshot
+// A snapshot that is still running after its run has ended was cut short, e.g. the process was killed.
+const (
+	SnapshotRunning  = "running"
+	SnapshotComplete = "complete"
+	SnapshotFailed   = "failed"
+)
+
+// One auction house dump we tried to import
+type Snapshot struct {
+	SnapshotID       int64
+	ConnectedRealmID int
+	AuctionHouseID   int
+	FactionID        sql.NullInt64
+	GameVersion      GameVersion
+	FetchedAt        int64     // The import time of the run, shared by every house it fetched
+	LastModified     time.Time // Zero if the API did not tell us
+	AuctionCount     int
+	ByteSize         int
+	Duration         time.Duration
+	Status           string
+	Error            string
+}
+
+// Records that we are about to fetch the auctions of house
+// The snapshot starts out running, FinishSnapshot records how it went.
+func BeginSnapshot(db *Database, house AuctionColumns, fetchedAt int64) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		ConnectedRealmID: house.ConnectedRealmID,
+		AuctionHouseID:   house.AuctionHouseID,
+		FactionID:        house.FactionID,
+		GameVersion:      house.GameVersion,
+		FetchedAt:        fetchedAt,
+		Status:           SnapshotRunning,
+	}
+
+	dialect := db.Dialect()
+
+	query := dialect.Rebind(`INSERT INTO Snapshots (connected_realm_id, auction_house_id, faction_id, game_version, fetched_at, status)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+
+	args := []interface{}{
+		snapshot.ConnectedRealmID, snapshot.AuctionHouseID, snapshot.FactionID, snapshot.GameVersion,
+		dialect.timestamp(fetchedAt), snapshot.Status,
+	}
+
+	// lib/pq does not support LastInsertId
+	if dialect == Postgres {
+		err := db.Handle.QueryRow(query+" RETURNING snapshot_id", args...).Scan(&snapshot.SnapshotID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return snapshot, nil
+	}
+
+	result, err := db.Handle.Exec(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.SnapshotID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Records the outcome of a snapshot, importErr is the error that stopped the import or nil if it is complete
+// A complete snapshot also becomes the Last-Modified time of its house, see AuctionColumns.LastModified.
+func FinishSnapshot(db *Database, snapshot *Snapshot, importErr error) error {
+	snapshot.Status = SnapshotComplete
+	snapshot.Error = ""
+
+	if importErr != nil {
+		snapshot.Status = SnapshotFailed
+		snapshot.Error = importErr.Error()
+	}
+
+	dialect := db.Dialect()
+
+	var lastModified interface{}
+	if !snapshot.LastModified.IsZero() {
+		lastModified = dialect.timestamp(snapshot.LastModified.Unix())
+	}
+
+	var errorMessage sql.NullString
+	if snapshot.Error != "" {
+		errorMessage = sql.NullString{String: snapshot.Error, Valid: true}
+	}
+
+	tx, err := db.Handle.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(dialect.Rebind(`UPDATE Snapshots
+		SET last_modified = ?, auction_count = ?, byte_size = ?, duration_ms = ?, status = ?, error = ?
+		WHERE snapshot_id = ?`),
+		lastModified, snapshot.AuctionCount, snapshot.ByteSize, snapshot.Duration.Milliseconds(),
+		snapshot.Status, errorMessage, snapshot.SnapshotID)
+
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Status == SnapshotComplete && lastModified != nil {
+		_, err = tx.Exec(dialect.Rebind(`UPDATE AuctionHouses SET last_modified = ?
+			WHERE connected_realm_id = ? AND auction_house_id = ?`),
+			lastModified, snapshot.ConnectedRealmID, snapshot.AuctionHouseID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Whether house already has a complete snapshot fetched at fetchedAt, e.g. one that is replayed from an Archive
+func HasSnapshot(db *Database, house AuctionColumns, fetchedAt int64) (bool, error) {
+	dialect := db.Dialect()
+
+	var count int
+
+	err := db.Handle.QueryRow(dialect.Rebind(`SELECT COUNT(*) FROM Snapshots
+		WHERE connected_realm_id = ? AND auction_house_id = ? AND fetched_at = ? AND status = ?`),
+		house.ConnectedRealmID, house.AuctionHouseID, dialect.timestamp(fetchedAt), SnapshotComplete).Scan(&count)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
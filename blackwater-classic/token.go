@@ -0,0 +1,303 @@
+package blackwater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/oauth2"
+)
+
+const (
+	DefaultTokenCachePath     = "blackwater.oauth"
+	DefaultTokenRefreshMargin = 5 * time.Minute
+)
+
+// How long we wait for another process to release the token cache lock,
+// and when a lock file is considered to be left behind by a crashed process
+const (
+	tokenLockTimeout = 10 * time.Second
+	tokenLockStale   = 30 * time.Second
+)
+
+// Hands out OAuth tokens from the client credentials flow
+//
+// Tokens are refreshed RefreshMargin before they expire and are cached in CachePath,
+// so that several processes can share one token. The cache is guarded by a lock file
+// and replaced atomically.
+type TokenSource struct {
+	ClientID      string
+	ClientSecret  string
+	TokenURL      string
+	CachePath     string // Empty disables the file cache
+	RefreshMargin time.Duration
+
+	httpClient *fasthttp.Client
+
+	mu          sync.Mutex
+	token       *oauth2.Token
+	invalidated string
+}
+
+var _ oauth2.TokenSource = (*TokenSource)(nil)
+
+// Used to unmarshal the response of the token endpoint
+type tokenJson struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func NewTokenSource(clientID string, clientSecret string, tokenURL string, cachePath string) *TokenSource {
+	return &TokenSource{
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		TokenURL:      tokenURL,
+		CachePath:     cachePath,
+		RefreshMargin: DefaultTokenRefreshMargin,
+	}
+}
+
+// Returns a token that is valid for at least RefreshMargin
+func (ts *TokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.usable(ts.token) {
+		return ts.token, nil
+	}
+
+	if ts.CachePath == "" {
+		token, err := ts.fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		ts.token = token
+		return token, nil
+	}
+
+	unlock, err := lockFile(ts.CachePath + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process might have refreshed the token while we waited for the lock
+	// A cache that can't be read is not overwritten, it might hold something else
+	cached, err := readCachedToken(ts.CachePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the cached oauth token %s: %w", ts.CachePath, err)
+	}
+
+	if ts.usable(cached) {
+		log.Println("Found a cached oauth token that will expire:", cached.Expiry)
+		ts.token = cached
+		return cached, nil
+	}
+
+	log.Println("Found no token or it expired or something went wrong. Fetching a new token")
+
+	token, err := ts.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Newly fetched oauth token will expire at:", token.Expiry)
+
+	err = writeCachedToken(ts.CachePath, token)
+	if err != nil {
+		log.Printf("Could not cache the oauth token: %q\n", err)
+	}
+
+	ts.token = token
+
+	return token, nil
+}
+
+// Marks accessToken as rejected, e.g. after a 401
+// The next call to Token will fetch a new token instead of handing it out again
+func (ts *TokenSource) Invalidate(accessToken string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.invalidated = accessToken
+
+	if ts.token != nil && ts.token.AccessToken == accessToken {
+		ts.token = nil
+	}
+}
+
+func (ts *TokenSource) usable(token *oauth2.Token) bool {
+	// Client credential tokens always expire, a token without an expiry comes from an old cache file
+	if token == nil || token.AccessToken == "" || token.Expiry.IsZero() {
+		return false
+	}
+
+	if token.AccessToken == ts.invalidated {
+		return false
+	}
+
+	return time.Now().Add(ts.RefreshMargin).Before(token.Expiry)
+}
+
+func (ts *TokenSource) fetch() (*oauth2.Token, error) {
+	req := fasthttp.AcquireRequest()
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(res)
+
+	url := fasthttp.AcquireURI()
+
+	// Set URL
+	url.Parse(nil, []byte(ts.TokenURL))
+	url.SetUsername(ts.ClientID)
+	url.SetPassword(ts.ClientSecret)
+	req.SetURI(url)
+	fasthttp.ReleaseURI(url)
+
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetBody([]byte("grant_type=client_credentials"))
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var err error
+	if ts.httpClient != nil {
+		err = ts.httpClient.Do(req, res)
+	} else {
+		err = fasthttp.Do(req, res)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode() != fasthttp.StatusOK {
+		return nil, newAPIError(ts.TokenURL, res)
+	}
+
+	var response tokenJson
+	err = json.Unmarshal(res.Body(), &response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.AccessToken == "" {
+		return nil, errors.New("token endpoint did not return an access token")
+	}
+
+	// The endpoint may hand out the rejected token again, a token it just issued is trusted
+	ts.invalidated = ""
+
+	return &oauth2.Token{
+		AccessToken: response.AccessToken,
+		TokenType:   response.TokenType,
+		Expiry:      time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// Returns nil without an error if nothing is cached yet, the file does not exist or is empty
+func readCachedToken(p string) (*oauth2.Token, error) {
+	bytes, err := os.ReadFile(p)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes) == 0 {
+		return nil, nil
+	}
+
+	var token oauth2.Token
+	err = json.Unmarshal(bytes, &token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Writes to a temporary file first and renames it,
+// so that readers never see a half written token
+func writeCachedToken(p string, token *oauth2.Token) error {
+	bytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(bytes)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(f.Name(), 0600)
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// Takes a lock that is shared between processes by creating p exclusively
+// Returns a function that releases the lock
+func lockFile(p string) (func(), error) {
+	deadline := time.Now().Add(tokenLockTimeout)
+
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+		if err == nil {
+			fmt.Fprintf(f, "%d", os.Getpid())
+			f.Close()
+
+			return func() { os.Remove(p) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		// The process holding the lock probably died
+		info, statErr := os.Stat(p)
+		if statErr == nil && time.Since(info.ModTime()) > tokenLockStale {
+			log.Printf("Removing stale lock file %s\n", p)
+			os.Remove(p)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", p)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,228 @@
+package blackwater
+
+import (
+	"blackwater/blackwater-classic/blackwatertest"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newTestTokenSource(server *blackwatertest.Server, cachePath string) *TokenSource {
+	return NewTokenSource(blackwatertest.ClientID, blackwatertest.ClientSecret, server.TokenURL(), cachePath)
+}
+
+func writeTestToken(t *testing.T, p string, token oauth2.Token) {
+	t.Helper()
+
+	bytes, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(p, bytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenSourceReusesCachedToken(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "blackwater.oauth")
+
+	first, err := newTestTokenSource(server, cachePath).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second process should pick up the cached token instead of fetching a new one
+	second, err := newTestTokenSource(server, cachePath).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.AccessToken != second.AccessToken {
+		t.Errorf("expected the cached token to be reused, got %q and %q", first.AccessToken, second.AccessToken)
+	}
+
+	if n := server.RequestCount("/token"); n != 1 {
+		t.Errorf("expected 1 token request, got %d", n)
+	}
+
+	if _, err := os.Stat(cachePath + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file was left behind: %v", err)
+	}
+}
+
+func TestTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "blackwater.oauth")
+	writeTestToken(t, cachePath, oauth2.Token{AccessToken: "about-to-expire", Expiry: time.Now().Add(time.Minute)})
+
+	token, err := newTestTokenSource(server, cachePath).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.AccessToken != blackwatertest.AccessToken {
+		t.Errorf("expected a fresh token, got %q", token.AccessToken)
+	}
+
+	cached, err := readCachedToken(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached.AccessToken != blackwatertest.AccessToken || cached.Expiry.Before(time.Now().Add(time.Hour)) {
+		t.Errorf("the fresh token was not cached: %+v", cached)
+	}
+}
+
+func TestTokenSourceConcurrentProcesses(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "blackwater.oauth")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := newTestTokenSource(server, cachePath).Token(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := server.RequestCount("/token"); n != 1 {
+		t.Errorf("expected the token to be fetched once, got %d requests", n)
+	}
+}
+
+func TestReadCachedToken(t *testing.T) {
+	dir := t.TempDir()
+
+	empty := filepath.Join(dir, "empty.oauth")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is cached yet
+	for _, p := range []string{filepath.Join(dir, "missing.oauth"), empty} {
+		if token, err := readCachedToken(p); token != nil || err != nil {
+			t.Errorf("%s: expected no token and no error, got %v, %v", filepath.Base(p), token, err)
+		}
+	}
+
+	// A cache that can't be read is an error
+	if _, err := readCachedToken(dir); err == nil {
+		t.Error("expected an error for a cache that is a directory")
+	}
+}
+
+func TestTokenSourceKeepsUnreadableCache(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "blackwater.oauth")
+	if err := os.WriteFile(cachePath, []byte("not a token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestTokenSource(server, cachePath).Token(); err == nil {
+		t.Error("expected an error for a cache that can't be read")
+	}
+
+	if n := server.RequestCount("/token"); n != 0 {
+		t.Errorf("expected no token request, got %d", n)
+	}
+
+	if bytes, _ := os.ReadFile(cachePath); string(bytes) != "not a token" {
+		t.Errorf("the cache was overwritten with %q", bytes)
+	}
+}
+
+func TestTokenSourceAcceptsReissuedToken(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	ts := newTestTokenSource(server, "")
+
+	// The fake server always issues the same token
+	ts.Invalidate(blackwatertest.AccessToken)
+
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token.AccessToken != blackwatertest.AccessToken {
+			t.Errorf("expected %q, got %q", blackwatertest.AccessToken, token.AccessToken)
+		}
+	}
+
+	if n := server.RequestCount("/token"); n != 1 {
+		t.Errorf("expected the reissued token to be kept, got %d token requests", n)
+	}
+}
+
+func TestTokenSourceRejectsWrongCredentials(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	ts := NewTokenSource("wrong", "credentials", server.TokenURL(), "")
+
+	if _, err := ts.Token(); !IsUnauthorized(err) {
+		t.Errorf("expected an unauthorized error for wrong credentials, got %v", err)
+	}
+}
+
+func TestAPIRetriesWithFreshTokenOn401(t *testing.T) {
+	server := blackwatertest.NewServer()
+	defer server.Close()
+
+	// The cached token has not expired, but the server no longer accepts it
+	cachePath := filepath.Join(t.TempDir(), "blackwater.oauth")
+	writeTestToken(t, cachePath, oauth2.Token{AccessToken: "revoked", Expiry: time.Now().Add(time.Hour)})
+
+	api, err := NewAPIWithOptions(blackwatertest.ClientID, blackwatertest.ClientSecret, APIOptions{
+		APIBaseURL:     server.URL,
+		OAuthURL:       server.TokenURL(),
+		TokenCachePath: cachePath,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.ClassicItem(13452); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := server.RequestCount("/token"); n != 1 {
+		t.Errorf("expected 1 token request, got %d", n)
+	}
+
+	if token, _ := api.Tokens().Token(); token.AccessToken != blackwatertest.AccessToken {
+		t.Errorf("expected the API to switch to the fresh token, got %q", token.AccessToken)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"blackwater/blackwater-classic"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// A house whose dump a worker has downloaded, waiting for the writer
+type fetchedHouse struct {
+	house    blackwater.AuctionColumns
+	dump     *blackwater.AuctionDump
+	info     blackwater.ResponseInfo
+	err      error
+	duration time.Duration
+}
+
+// What an auctions run did
+type IngestSummary struct {
+	Houses    int
+	Imported  int
+	Unchanged int
+	Failed    int
+	Auctions  int
+	Bytes     int
+	Duration  time.Duration
+}
+
+func (s IngestSummary) String() string {
+	return fmt.Sprintf("Imported %d auctions from %d of %d auction houses in %s, %d unchanged, %d failed, %d bytes downloaded",
+		s.Auctions, s.Imported, s.Houses, s.Duration.Round(time.Millisecond), s.Unchanged, s.Failed, s.Bytes)
+}
+
+// Fetches the auctions of houses and stores them, every attempt is recorded as a snapshot, failed ones included
+//
+// Up to workers houses are downloaded at the same time. They all wait for the same rate limiter,
+// so the pool stays within the API limits however many workers there are.
+// The dumps are written one at a time by the calling goroutine, SQLite allows only one writer.
+// The writer decodes a dump while it inserts it, so only the compressed dumps and one batch of auctions are held in memory.
+// A dump that has not changed since the last import is skipped without a snapshot.
+// If archive is not nil, the workers also write every new dump to it before it is imported.
+func IngestAuctionHouses(api *blackwater.API, db *blackwater.Database, houses []blackwater.AuctionColumns, importTime int64, workers int, archive *blackwater.Archive) IngestSummary {
+	start := time.Now()
+	summary := IngestSummary{Houses: len(houses)}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan blackwater.AuctionColumns)
+
+	// Workers block once the writer falls behind, so at most 2 * workers dumps are held in memory
+	results := make(chan fetchedHouse, workers)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for house := range jobs {
+				results <- fetchHouse(api, house, importTime, archive)
+			}
+		}()
+	}
+
+	go func() {
+		for _, house := range houses {
+			jobs <- house
+		}
+
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	done := 0
+
+	for fetched := range results {
+		done++
+		house := fetched.house
+
+		auctionsCount, err := writeHouse(db, fetched, importTime)
+		summary.Bytes += fetched.info.Bytes
+
+		if errors.Is(err, blackwater.ErrNotModified) {
+			log.Printf("(%d/%d) The %s auctions of %s (%d) have not changed since the last import\n",
+				done, len(houses), houseName(house), house.Name, house.ConnectedRealmID)
+			summary.Unchanged++
+			continue
+		}
+
+		if err != nil {
+			log.Printf("(%d/%d) Could not import the %s auctions of %s (%d): %q\n",
+				done, len(houses), houseName(house), house.Name, house.ConnectedRealmID, err)
+			summary.Failed++
+			continue
+		}
+
+		log.Printf("(%d/%d) Imported %d %s auctions to the DB for %s (%d)\n",
+			done, len(houses), auctionsCount, houseName(house), house.Name, house.ConnectedRealmID)
+
+		summary.Imported++
+		summary.Auctions += auctionsCount
+	}
+
+	summary.Duration = time.Since(start)
+
+	return summary
+}
+
+// Downloads the dump of a house and archives it, runs in a worker
+func fetchHouse(api *blackwater.API, house blackwater.AuctionColumns, importTime int64, archive *blackwater.Archive) fetchedHouse {
+	start := time.Now()
+
+	var modifiedSince time.Time
+	if house.LastModified.Valid {
+		modifiedSince = house.LastModified.Time
+	}
+
+	// Workers share the API, the house says which region's token to use
+	dump, info, err := api.AuctionDumpFromHrefSince(blackwater.Region(house.Region), house.Href, modifiedSince)
+
+	if err == nil && unchanged(house, info) {
+		err = blackwater.ErrNotModified
+	}
+
+	// The dump is kept even if it can't be imported, an archive that can't be written does not stop the import
+	if err == nil && archive != nil {
+		p, stored, archiveErr := archive.Store(house, importTime, dump)
+
+		if archiveErr != nil {
+			log.Printf("Could not archive the %s auctions of %s (%d): %q\n", houseName(house), house.Name, house.ConnectedRealmID, archiveErr)
+		} else if stored {
+			log.Printf("Archived the %s auctions of %s (%d) to %s\n", houseName(house), house.Name, house.ConnectedRealmID, p)
+		}
+	}
+
+	return fetchedHouse{house: house, dump: dump, info: info, err: err, duration: time.Since(start)}
+}
+
+// Records the snapshot of a fetched house, stores its auctions and tracks their lifecycles, runs in the writer
+func writeHouse(db *blackwater.Database, fetched fetchedHouse, importTime int64) (int, error) {
+	if errors.Is(fetched.err, blackwater.ErrNotModified) {
+		return 0, fetched.err
+	}
+
+	start := time.Now()
+
+	snapshot, err := blackwater.BeginSnapshot(db, fetched.house, importTime)
+
+	if err != nil {
+		return 0, err
+	}
+
+	snapshot.ByteSize = fetched.info.Bytes
+	snapshot.LastModified = fetched.info.LastModified
+
+	err = fetched.err
+
+	if err == nil {
+		snapshot.AuctionCount, err = blackwater.InsertAuctionsFrom(db, fetched.dump, importTime, fetched.house, snapshot.SnapshotID)
+	}
+
+	// A dump that was only partly stored would end every auction it is missing
+	if err == nil {
+		err = blackwater.TrackLifecycles(db, snapshot)
+	}
+
+	snapshot.Duration = fetched.duration + time.Since(start)
+
+	finishErr := blackwater.FinishSnapshot(db, snapshot, err)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if finishErr != nil {
+		return 0, finishErr
+	}
+
+	return snapshot.AuctionCount, nil
+}
+
+// Whether the dump is the one we imported last time
+// The API does not always answer If-Modified-Since with 304, so the Last-Modified times are compared too.
+func unchanged(house blackwater.AuctionColumns, info blackwater.ResponseInfo) bool {
+	if !house.LastModified.Valid || info.LastModified.IsZero() {
+		return false
+	}
+
+	return !info.LastModified.After(house.LastModified.Time)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"blackwater/blackwater-classic"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -10,11 +9,13 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/valyala/fasthttp"
 )
 
 func ReadEntireFile(p string) ([]byte, error) {
@@ -72,7 +73,7 @@ func ReadDatabaseConfig(p string) (blackwater.Database, error) {
 	return result, nil
 }
 
-func ReadServerConfig(api *blackwater.API, serverPath string) error {
+func ReadServerConfig(api *blackwater.API, db *blackwater.Database, serverPath string) error {
 	log.Println("Reading from:", serverPath)
 
 	bytes, err := ReadEntireFile(serverPath)
@@ -89,55 +90,214 @@ func ReadServerConfig(api *blackwater.API, serverPath string) error {
 		return err
 	}
 
-	blackwater.UpdateRealmTable(api, databaseFile, server_json)
+	// The realm names are written in this locale
+	if len(server_json.Locale) > 0 {
+		locale, err := blackwater.ParseLocale(server_json.Locale)
+
+		if err != nil {
+			return err
+		}
+
+		api.SetRegion(api.Region(), locale)
+	}
+
+	blackwater.UpdateRealmTable(api, db, server_json)
 
 	return nil
 }
 
-func FetchFactionAH(api *blackwater.API, db *sql.DB, href string, importTime int64, connectedRealmID int, faction_id int) (int, error) {
-	var auctionJson blackwater.AuctionJson
-	response, err := api.FetchCompressedFromHref(href)
+func FetchCommodities(api *blackwater.API, db *blackwater.Database, importTime int64, region blackwater.Region) (int, error) {
+	commoditiesJson, err := api.Commodities()
 
-	auctionCount := 0
+	if err != nil {
+		return 0, err
+	}
+
+	err = blackwater.InsertCommodities(db, *commoditiesJson, importTime, region)
 
 	if err != nil {
-		return auctionCount, err
+		return 0, err
 	}
 
-	data, err := response.BodyGunzip()
+	return len(commoditiesJson.Auctions), nil
+}
+
+func FileExists(p string) error {
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		return err
+	}
 
+	return nil
+}
+
+// Creates the API client with the credentials in CLIENT_ID and CLIENT_SECRET
+// Only the subcommands that call Blizzard create one, it fetches a token right away.
+func NewAPI() (*blackwater.API, error) {
+	api, err := blackwater.NewAPIWithOptions(os.Getenv("CLIENT_ID"), os.Getenv("CLIENT_SECRET"), ReadAPIOptions())
 	if err != nil {
-		return auctionCount, err
+		return nil, err
+	}
+
+	log.Println("Successfully created an API client.")
+
+	return api, nil
+}
+
+// Reads the optional API settings from the environment
+// BLACKWATER_API_URL and BLACKWATER_OAUTH_URL may contain {region}, e.g. http://localhost:8080 or https://{region}.api.blizzard.com
+func ReadAPIOptions() blackwater.APIOptions {
+	return blackwater.APIOptions{
+		APIBaseURL:        os.Getenv("BLACKWATER_API_URL"),
+		OAuthURL:          os.Getenv("BLACKWATER_OAUTH_URL"),
+		RequestsPerSecond: readIntEnv("BLACKWATER_REQUESTS_PER_SECOND"),
+		RequestsPerHour:   readIntEnv("BLACKWATER_REQUESTS_PER_HOUR"),
+	}
+}
+
+// Returns 0 if the variable is not set or not a number
+func readIntEnv(name string) int {
+	value := os.Getenv(name)
+
+	if value == "" {
+		return 0
 	}
 
-	log.Println("Marshaling")
-	err = json.Unmarshal(data, &auctionJson)
-	log.Println("Marshaling done")
-	auctionCount = len(auctionJson.Auctions)
+	n, err := strconv.Atoi(value)
 
 	if err != nil {
-		log.Printf("Could not unmarshal the AH data\n%q\n", err)
-	} else {
-		err = blackwater.InsertAuctions(db, auctionJson, importTime, connectedRealmID, faction_id)
+		log.Printf("Ignoring %s: %q\n", name, err)
+		return 0
+	}
 
-		if err != nil {
-			return 0, err
-		}
+	return n
+}
+
+// Adds the flag that selects which game version a subcommand works on
+func gameVersionFlag(fs *flag.FlagSet) *string {
+	return fs.String("game", "era", "Game version: era, classic (progression Classic) or retail.")
+}
+
+func SetGameVersion(api *blackwater.API, name string) error {
+	gameVersion, err := blackwater.ParseGameVersion(name)
+
+	if err != nil {
+		return err
 	}
 
-	fasthttp.ReleaseResponse(response)
+	log.Printf("Setting Game Version to %s\n", gameVersion)
+	api.SetGameVersion(gameVersion)
 
-	return auctionCount, nil
+	return nil
 }
 
-func FileExists(p string) error {
-	if _, err := os.Stat(p); os.IsNotExist(err) {
+func SetRegion(api *blackwater.API, code string, localeName string) error {
+	region, err := blackwater.ParseRegion(code)
+
+	if err != nil {
 		return err
 	}
 
+	var locale blackwater.Locale
+
+	if len(localeName) > 0 {
+		locale, err = blackwater.ParseLocale(localeName)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	api.SetRegion(region, locale)
+
 	return nil
 }
 
+// Parses a comma separated list of region codes, e.g. "eu,us"
+func ParseRegions(codes string) ([]blackwater.Region, error) {
+	regions := []blackwater.Region{}
+
+	for _, code := range strings.Split(codes, ",") {
+		region, err := blackwater.ParseRegion(strings.TrimSpace(code))
+
+		if err != nil {
+			return nil, err
+		}
+
+		regions = append(regions, region)
+	}
+
+	return regions, nil
+}
+
+// Runs migrate up, down or status and prints what happened
+func Migrate(db *blackwater.Database, action string, steps int) error {
+	var migrations []blackwater.Migration
+	var err error
+
+	if action == "up" {
+		migrations, err = blackwater.MigrateUp(db)
+	} else if action == "down" {
+		migrations, err = blackwater.MigrateDown(db, steps)
+	} else if action == "status" {
+		states, err := blackwater.MigrationStatus(db)
+
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			status := "pending"
+			if state.Applied {
+				status = "applied " + time.Unix(state.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+
+			fmt.Printf("%04d_%s: %s\n", state.Version, state.Name, status)
+		}
+
+		return nil
+	} else {
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+
+	for _, migration := range migrations {
+		fmt.Printf("%s %04d_%s\n", action, migration.Version, migration.Name)
+	}
+
+	return err
+}
+
+// Splits a comma separated list, an empty string is an empty list
+func splitList(s string) []string {
+	list := []string{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// Era realms are listed in eu-servers.json and us-servers.json,
+// the other game versions in e.g. eu-classic-servers.json and us-retail-servers.json
+func ServerConfigPath(region string, gameVersion blackwater.GameVersion) string {
+	if gameVersion == blackwater.Era {
+		return region + "-servers.json"
+	}
+
+	return fmt.Sprintf("%s-%s-servers.json", region, gameVersion)
+}
+
+// Returns e.g. "horde", or the name of the auction house if it has no known faction
+func houseName(house blackwater.AuctionColumns) string {
+	if house.FactionID.Valid && int(house.FactionID.Int64) < len(blackwater.FactionNames) {
+		return blackwater.FactionNames[house.FactionID.Int64]
+	}
+
+	return fmt.Sprintf("%q (%d)", house.AuctionHouseName, house.AuctionHouseID)
+}
+
 const (
 	databaseFolder = "data/db"
 	databaseFile   = databaseFolder + "/blackwater.db" // This is where all the data will go
@@ -153,29 +313,65 @@ func main() {
 	log.SetOutput(f)
 	defer f.Close()
 
-	//update := flag.NewFlagSet("update", flag.ExitOnError)
-
-	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
-	initSql := initCmd.Bool("sql", false, "Sets up the database if it doesn't exist, using sqllite3.")
-
 	flag.Parse()
 
 	if len(os.Args) < 2 {
-		fmt.Println("Expected: blackwater [init|run|update] [flags]")
+		fmt.Println("Expected: blackwater [init|migrate|update|auctions|items|com|reset-realms|convert-auctions|replay] [flags]")
 		os.Exit(1)
 	}
 
-	os.Mkdir("data", 0777)
+	err = run(os.Args[1:])
 
-	api, apiCreationError := blackwater.NewAPI(os.Getenv("CLIENT_ID"), os.Getenv("CLIENT_SECRET"))
-	if apiCreationError != nil {
-		log.Fatal(apiCreationError)
+	if err != nil {
+		log.Fatal(err)
 	}
+}
 
-	log.Println("Successfully created an API client.")
-	log.Println("Setting Game Version to Classic Era")
+// Runs a single subcommand, args[0] is the name of the subcommand
+func run(args []string) error {
 
-	api.SetGameVersion(blackwater.Era)
+	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
+	initCmd.Bool("sql", true, "Kept for old scripts, init always sets up the database.")
+
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateSteps := migrateCmd.Int("steps", 1, "Number of migrations that migrate down reverts.")
+
+	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
+	updateGame := gameVersionFlag(updateCmd)
+	updateDiscover := updateCmd.Bool("discover", false, "Discover every connected realm of the regions instead of reading the servers files.")
+	updateRegions := updateCmd.String("regions", "eu,us", "Comma separated list of regions to discover realms in.")
+	updateInclude := updateCmd.String("include", "", "Comma separated list of realm names or slugs to discover, all realms if empty.")
+	updateExclude := updateCmd.String("exclude", "", "Comma separated list of realm names or slugs to leave out.")
+	updateTimezones := updateCmd.String("timezones", "", "Comma separated list of timezones to discover, e.g. Europe/Paris. All timezones if empty.")
+	updateTournament := updateCmd.Bool("tournament", false, "Also discover tournament realms.")
+	updateHouses := updateCmd.String("houses", "", "Comma separated list of auction houses to fetch for discovered realms, e.g. alliance,horde. All houses if empty.")
+
+	auctionsCmd := flag.NewFlagSet("auctions", flag.ExitOnError)
+	auctionsGame := gameVersionFlag(auctionsCmd)
+	auctionsWorkers := auctionsCmd.Int("workers", 8, "Number of auction houses to fetch at the same time, the API rate limits are shared by all of them.")
+	auctionsStorage := auctionsCmd.String("storage", "full", "How to store the auctions: full keeps a row per auction and time left in Auctions, delta only their changes.")
+	auctionsArchive := auctionsCmd.String("archive", "", "Directory to keep the raw gzipped dumps in, e.g. data/archive. Nothing is archived if it is empty.")
+
+	itemsCmd := flag.NewFlagSet("items", flag.ExitOnError)
+	itemsGame := gameVersionFlag(itemsCmd)
+	itemsRegion := itemsCmd.String("region", "eu", "Region to look up the items in: eu, us, kr, tw or cn.")
+	itemsLocale := itemsCmd.String("locale", "", "Locale of the item names, e.g. de_DE. Defaults to the locale of the region. Cached items keep the locale they were cached in.")
+
+	comCmd := flag.NewFlagSet("com", flag.ExitOnError)
+	comRegions := comCmd.String("regions", "eu,us", "Comma separated list of regions to fetch commodities for.")
+
+	convertCmd := flag.NewFlagSet("convert-auctions", flag.ExitOnError)
+	convertDelete := convertCmd.Bool("delete", false, "Delete the rows of Auctions once they are converted.")
+
+	replayCmd := flag.NewFlagSet("replay", flag.ExitOnError)
+	replayArchive := replayCmd.String("archive", "data/archive", "Directory of the archived dumps, see the -archive flag of auctions.")
+	replayStorage := replayCmd.String("storage", "full", "How to store the auctions: full or delta, as for auctions.")
+	replayGame := replayCmd.String("game", "", "Only replay the dumps of this game version: era, classic or retail. All of them if empty.")
+
+	err := os.MkdirAll(databaseFolder, 0777)
+	if err != nil {
+		return err
+	}
 
 	database, err := ReadDatabaseConfig("db.json")
 
@@ -185,41 +381,142 @@ func main() {
 		database = blackwater.NewLocalDatabase(databaseFile)
 	}
 
-	if os.Args[1] == "init" {
-		initCmd.Parse(os.Args[2:])
+	if args[0] == "init" {
+		initCmd.Parse(args[1:])
 		log.Println("Creating database.")
 
-		// TODO: SQL init
-		if *initSql {
-			err = blackwater.SetupDatabase(&database)
+		// Applies every pending migration
+		err = blackwater.SetupDatabase(&database)
+
+		if err != nil {
+			return err
+		}
+
+	} else if args[0] == "migrate" {
+		if len(args) < 2 {
+			return errors.New("expected: blackwater migrate [up|down|status] [flags]")
+		}
+
+		migrateCmd.Parse(args[2:])
+
+		err = database.OpenConnection()
+
+		if err != nil {
+			log.Printf("Could not open DB.\n")
+			return err
+		}
+
+		defer database.CloseConnection()
+
+		err = Migrate(&database, args[1], *migrateSteps)
+
+		if err != nil {
+			return err
+		}
+
+	} else if args[0] == "update" {
+		updateCmd.Parse(args[1:])
+
+		api, err := NewAPI()
+		if err != nil {
+			return err
+		}
+
+		err = SetGameVersion(api, *updateGame)
+		if err != nil {
+			return err
+		}
+
+		err = database.OpenConnection()
+
+		if err != nil {
+			log.Printf("Could not open DB.\n")
+			return err
+		}
+
+		defer database.CloseConnection()
+
+		if *updateDiscover {
+			regions, err := ParseRegions(*updateRegions)
 
 			if err != nil {
-				log.Print(err)
+				return err
+			}
+
+			filter := blackwater.RealmFilter{
+				Include:    splitList(*updateInclude),
+				Exclude:    splitList(*updateExclude),
+				Timezones:  splitList(*updateTimezones),
+				Tournament: *updateTournament,
+				Houses:     splitList(*updateHouses),
+			}
+
+			for _, region := range regions {
+				api.SetRegion(region, "")
+
+				err = blackwater.DiscoverRealms(api, &database, filter)
+
+				if err != nil {
+					log.Printf("Could not discover the realms in %s: %q\n", region, err)
+				}
 			}
 
+			return nil
 		}
 
-	} else if os.Args[1] == "update" {
+		// Every region that has a servers file, e.g. eu-servers.json or kr-servers.json
+		for _, region := range blackwater.AllRegions {
+			serverPath := ServerConfigPath(region.String(), api.GameVersion())
 
-		api.SetRegion(blackwater.EU, blackwater.EnGB)
-		ReadServerConfig(api, "eu-servers.json")
+			if FileExists(serverPath) != nil {
+				continue
+			}
 
-		api.SetRegion(blackwater.US, blackwater.EnUS)
-		ReadServerConfig(api, "us-servers.json")
+			api.SetRegion(region, "")
 
-	} else if os.Args[1] == "auctions" {
+			err = ReadServerConfig(api, &database, serverPath)
 
-		err := database.OpenConnection()
+			if err != nil {
+				log.Printf("Could not read %s: %q\n", serverPath, err)
+			}
+		}
+
+	} else if args[0] == "auctions" {
+		auctionsCmd.Parse(args[1:])
+
+		api, err := NewAPI()
+		if err != nil {
+			return err
+		}
+
+		err = SetGameVersion(api, *auctionsGame)
+		if err != nil {
+			return err
+		}
+
+		database.AuctionStorage, err = blackwater.ParseAuctionStorage(*auctionsStorage)
+		if err != nil {
+			return err
+		}
+
+		err = database.OpenConnection()
 
 		if err != nil {
 			log.Printf("Could not open DB.\n")
-			log.Fatal(err)
+			return err
 		}
 
-		// 1. Look up every row in the realm table
-		rowsQuery, err := database.Handle.Query("SELECT connected_realm_id, region, name, alliance_ah_href, horde_ah_href, neutral_ah_href FROM ConnectedRealms")
+		defer database.CloseConnection()
+
+		// 1. Look up every auction house of the realms in the realm table
+		rowsQuery, err := database.Handle.Query(database.Dialect().Rebind(`SELECT C.connected_realm_id, C.region, C.game_version, C.name,
+			A.auction_house_id, A.name, A.faction_id, A.href, A.last_modified
+			FROM AuctionHouses A
+			JOIN ConnectedRealms C ON A.connected_realm_id = C.connected_realm_id
+			WHERE C.game_version = ? AND A.enabled = TRUE
+			ORDER BY C.connected_realm_id, A.auction_house_id`), api.GameVersion())
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		rows := []blackwater.AuctionColumns{}
@@ -231,13 +528,16 @@ func main() {
 
 			err = rowsQuery.Scan(&columns.ConnectedRealmID,
 				&columns.Region,
+				&columns.GameVersion,
 				&columns.Name,
-				&columns.AllianceHref,
-				&columns.HordeHref,
-				&columns.NeutralHref)
+				&columns.AuctionHouseID,
+				&columns.AuctionHouseName,
+				&columns.FactionID,
+				&columns.Href,
+				&columns.LastModified)
 
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
 			rows = append(rows, columns)
@@ -246,85 +546,195 @@ func main() {
 		err = rowsQuery.Err()
 
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		numberOfAuctionsImported := 0
-		importTime := time.Now().Unix()
+		houses := []blackwater.AuctionColumns{}
 
+		// Fetch AH data using their hrefs
 		for _, row := range rows {
+			if len(row.Href) > 0 {
+				houses = append(houses, row)
+			}
+		}
+
+		var archive *blackwater.Archive
+		if len(*auctionsArchive) > 0 {
+			archive = blackwater.NewArchive(*auctionsArchive)
+		}
 
-			// Fetch AH data using their hrefs
-			// FetchFactionAH() will also save the auctions in the database
+		summary := IngestAuctionHouses(api, &database, houses, time.Now().Unix(), *auctionsWorkers, archive)
 
-			allianceAuctionsCount, err := FetchFactionAH(api, database.Handle, row.AllianceHref, importTime, row.ConnectedRealmID, 0)
+		log.Println("Finished downloading auction house data.")
+		log.Println(summary)
+		fmt.Println(summary)
+	} else if args[0] == "com" {
+		comCmd.Parse(args[1:])
 
-			if err != nil {
-				log.Println(err)
-			} else {
-				log.Printf("Imported %d alliance auctions to the DB for %s (%d)\n", allianceAuctionsCount, row.Name, row.ConnectedRealmID)
+		api, err := NewAPI()
+		if err != nil {
+			return err
+		}
 
-			}
+		// Commodities only exist on Retail and are shared by every realm in a region
+		api.SetGameVersion(blackwater.Retail)
 
-			hordeAuctionsCount, err := FetchFactionAH(api, database.Handle, row.HordeHref, importTime, row.ConnectedRealmID, 1)
+		err = database.OpenConnection()
 
-			if err != nil {
-				log.Println(err)
-			} else {
-				log.Printf("Imported %d horde auctions to the DB for %s (%d)\n", hordeAuctionsCount, row.Name, row.ConnectedRealmID)
+		if err != nil {
+			log.Printf("Could not open DB.\n")
+			return err
+		}
 
-			}
+		defer database.CloseConnection()
+
+		importTime := time.Now().Unix()
+		numberOfCommoditiesImported := 0
+
+		regions, err := ParseRegions(*comRegions)
+
+		if err != nil {
+			return err
+		}
+
+		for _, region := range regions {
+			api.SetRegion(region, "")
 
-			NeutralAuctionsCount, err := FetchFactionAH(api, database.Handle, row.NeutralHref, importTime, row.ConnectedRealmID, 2)
+			commoditiesCount, err := FetchCommodities(api, &database, importTime, region)
 
 			if err != nil {
 				log.Println(err)
-			} else {
-				log.Printf("Imported %d neutral auctions to the DB for %s (%d)\n", NeutralAuctionsCount, row.Name, row.ConnectedRealmID)
-
+				continue
 			}
 
-			numberOfAuctionsImported += allianceAuctionsCount
-			numberOfAuctionsImported += hordeAuctionsCount
-			numberOfAuctionsImported += NeutralAuctionsCount
+			log.Printf("Imported %d commodities to the DB for %s\n", commoditiesCount, region)
 
+			numberOfCommoditiesImported += commoditiesCount
 		}
 
-		database.CloseConnection()
+		log.Println("Finished downloading commodities.")
+		log.Printf("Imported a total of %d commodities\n", numberOfCommoditiesImported)
+
+	} else if args[0] == "items" {
+		itemsCmd.Parse(args[1:])
+
+		api, err := NewAPI()
+		if err != nil {
+			return err
+		}
+
+		err = SetGameVersion(api, *itemsGame)
+		if err != nil {
+			return err
+		}
+
+		err = SetRegion(api, *itemsRegion, *itemsLocale)
+		if err != nil {
+			return err
+		}
 
-		log.Println("Finished downloading auction house data.")
-		log.Printf("Imported a total of %d auctions\n", numberOfAuctionsImported)
-	} else if os.Args[1] == "items" {
 		// Scan through the Auctions table to see if there is an item in there that is not cached, i.e in the items table
-		err := database.OpenConnection()
+		err = database.OpenConnection()
 
 		if err != nil {
 			log.Printf("Could not open DB.\n")
-			log.Fatal(err)
+			return err
 		}
 
-		err = blackwater.CacheItems(api, database.Handle)
+		defer database.CloseConnection()
+
+		err = blackwater.CacheItems(api, &database)
 
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-	} else if os.Args[1] == "reset-realms" {
+	} else if args[0] == "reset-realms" {
 
-		err := database.OpenConnection()
+		err = database.OpenConnection()
 
 		if err != nil {
 			log.Printf("Could not open DB.\n")
-			log.Fatal(err)
+			return err
+		}
+
+		defer database.CloseConnection()
+
+		err = blackwater.ResetRealms(&database)
+
+		if err != nil {
+			return err
 		}
 
-		_, err = database.Handle.Exec(`DELETE FROM ConnectedRealms`)
+		log.Println("Deleted all records of realms, the auction houses with snapshots are disabled.")
+
+	} else if args[0] == "convert-auctions" {
+		convertCmd.Parse(args[1:])
+
+		err = database.OpenConnection()
 
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("Could not open DB.\n")
+			return err
 		}
 
-		log.Println("Deleted all records of realms.")
+		defer database.CloseConnection()
+
+		// Auctions stored before delta storage become lifecycles and changes
+		summary, err := blackwater.ConvertAuctions(&database, *convertDelete)
+
+		if err != nil {
+			return err
+		}
+
+		log.Println(summary)
+		fmt.Println(summary)
+
+	} else if args[0] == "replay" {
+		replayCmd.Parse(args[1:])
+
+		database.AuctionStorage, err = blackwater.ParseAuctionStorage(*replayStorage)
+		if err != nil {
+			return err
+		}
+
+		dumps, err := blackwater.NewArchive(*replayArchive).Dumps()
+
+		if err != nil {
+			return err
+		}
+
+		if len(*replayGame) > 0 {
+			gameVersion, err := blackwater.ParseGameVersion(*replayGame)
+			if err != nil {
+				return err
+			}
+
+			selected := []blackwater.ArchivedDump{}
+
+			for _, dump := range dumps {
+				if dump.House.GameVersion == gameVersion {
+					selected = append(selected, dump)
+				}
+			}
+
+			dumps = selected
+		}
+
+		err = database.OpenConnection()
+
+		if err != nil {
+			log.Printf("Could not open DB.\n")
+			return err
+		}
+
+		defer database.CloseConnection()
+
+		summary := ReplayArchive(&database, dumps)
+
+		log.Println(summary)
+		fmt.Println(summary)
 	}
 
+	return nil
 }
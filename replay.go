@@ -0,0 +1,91 @@
+package main
+
+import (
+	"blackwater/blackwater-classic"
+	"fmt"
+	"log"
+	"time"
+)
+
+// What a replay did
+type ReplaySummary struct {
+	Dumps    int
+	Imported int
+	Skipped  int
+	Failed   int
+	Auctions int
+	Duration time.Duration
+}
+
+func (s ReplaySummary) String() string {
+	return fmt.Sprintf("Replayed %d auctions from %d of %d archived dumps in %s, %d already imported, %d failed",
+		s.Auctions, s.Imported, s.Dumps, s.Duration.Round(time.Millisecond), s.Skipped, s.Failed)
+}
+
+// Imports archived dumps the way the auctions subcommand imports downloaded ones, oldest first
+// Every dump becomes a snapshot fetched at the time it was archived, so lifecycles are tracked as they were.
+// Dumps whose house already has a complete snapshot at that time are skipped, a replay can be run again.
+// The connected realm and house of a dump are stored first if the database does not know them yet.
+func ReplayArchive(db *blackwater.Database, dumps []blackwater.ArchivedDump) ReplaySummary {
+	start := time.Now()
+	summary := ReplaySummary{Dumps: len(dumps)}
+
+	// Houses whose connected realm and house rows exist
+	stored := map[blackwater.AuctionColumns]bool{}
+
+	for i, archived := range dumps {
+		house := archived.House
+
+		if !stored[house] {
+			err := blackwater.EnsureAuctionHouse(db, house)
+
+			if err != nil {
+				log.Printf("(%d/%d) Could not store the house of %s: %q\n", i+1, len(dumps), archived.Path, err)
+				summary.Failed++
+				continue
+			}
+
+			stored[house] = true
+		}
+
+		imported, err := blackwater.HasSnapshot(db, house, archived.FetchedAt)
+
+		if err == nil && imported {
+			summary.Skipped++
+			continue
+		}
+
+		if err != nil {
+			log.Printf("(%d/%d) Could not replay %s: %q\n", i+1, len(dumps), archived.Path, err)
+			summary.Failed++
+			continue
+		}
+
+		loadStart := time.Now()
+		dump, err := archived.Load()
+
+		fetched := fetchedHouse{house: house, dump: dump, err: err, duration: time.Since(loadStart)}
+
+		if dump != nil {
+			fetched.info.Bytes = len(dump.Body)
+		}
+
+		// A dump that can't be read is recorded as a failed snapshot, like a download that failed
+		auctionsCount, err := writeHouse(db, fetched, archived.FetchedAt)
+
+		if err != nil {
+			log.Printf("(%d/%d) Could not replay %s: %q\n", i+1, len(dumps), archived.Path, err)
+			summary.Failed++
+			continue
+		}
+
+		log.Printf("(%d/%d) Replayed %d auctions from %s\n", i+1, len(dumps), auctionsCount, archived.Path)
+
+		summary.Imported++
+		summary.Auctions += auctionsCount
+	}
+
+	summary.Duration = time.Since(start)
+
+	return summary
+}
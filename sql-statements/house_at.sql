@@ -0,0 +1,33 @@
+-- SQLite
+-- The auctions of a house at its latest complete snapshot before a time, see HouseAt for the same in Go
+-- Full snapshots are rebuilt from the rows of Auctions that span them, delta snapshots from AuctionLifecycles and AuctionChanges.
+WITH S AS (
+    SELECT fetched_at FROM Snapshots
+    WHERE connected_realm_id = 5284 AND auction_house_id = 2 AND status = 'complete'
+    AND fetched_at <= strftime('%s', '2024-03-01 12:00:00')
+    ORDER BY fetched_at DESC, snapshot_id DESC
+    LIMIT 1
+),
+Listings AS (
+    SELECT 0 AS source, A.auction_id, A.game_version, A.item_id, A.item_rand, A.quantity, A.bid, A.buyout, A.unit_price, A.time_left
+    FROM Auctions A
+    JOIN Snapshots F ON F.snapshot_id = A.snapshot_id
+    JOIN Snapshots L ON L.snapshot_id = A.last_snapshot_id
+    JOIN S ON F.fetched_at <= S.fetched_at AND L.fetched_at >= S.fetched_at
+    WHERE A.connected_realm_id = 5284 AND A.auction_house_id = 2
+    UNION ALL
+    SELECT 1 AS source, L.auction_id, L.game_version, L.item_id, L.item_rand, L.quantity, C.bid, L.buyout, L.unit_price, C.time_left
+    FROM AuctionChanges C
+    JOIN AuctionLifecycles L ON L.connected_realm_id = C.connected_realm_id AND L.auction_id = C.auction_id
+    JOIN S ON L.ended_at IS NULL OR L.ended_at > S.fetched_at
+    WHERE C.connected_realm_id = 5284 AND C.auction_house_id = 2 AND C.time_left IS NOT NULL
+    AND C.changed_at = (SELECT MAX(C2.changed_at) FROM AuctionChanges C2
+        WHERE C2.connected_realm_id = C.connected_realm_id AND C2.auction_id = C.auction_id AND C2.changed_at <= S.fetched_at)
+)
+-- The row of Auctions wins if an auction has both, SQLite takes the other columns from the row with the MIN
+SELECT Listings.auction_id, Listings.item_id, I.name, Listings.item_rand, Listings.quantity, Listings.bid, Listings.buyout, Listings.unit_price,
+    Listings.time_left, MIN(source) AS source
+FROM Listings
+LEFT JOIN Items I ON I.item_id = Listings.item_id AND I.game_version = Listings.game_version
+GROUP BY Listings.auction_id
+ORDER BY Listings.item_id, Listings.unit_price;
@@ -1,4 +1,4 @@
 SELECT * FROM Auctions
-WHERE buyout > 0
-ORDER BY buyout ASC
-LIMIT 10;
\ No newline at end of file
+WHERE unit_price IS NOT NULL
+ORDER BY unit_price ASC
+LIMIT 10;
@@ -0,0 +1,4 @@
+SELECT R.name, S.status, S.population, S.has_queue, datetime(S.timestamp, 'unixepoch') AS time
+FROM ConnectedRealmStatus S
+JOIN Realms R ON S.connected_realm_id = R.connected_realm_id
+ORDER BY R.name, S.timestamp;
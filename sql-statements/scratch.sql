@@ -1,6 +1,7 @@
-SELECT * FROM Auctions
-WHERE item_id = 13452
-AND faction_id = 0
-AND connected_realm_id = 5284
-AND timestamp >= strftime('%s', 'now', '-7 days')
-ORDER BY buyout ASC;
\ No newline at end of file
+SELECT A.*, S.status FROM Auctions A
+JOIN Snapshots S ON A.last_snapshot_id = S.snapshot_id
+WHERE A.item_id = 13452
+AND S.faction_id = 0
+AND S.connected_realm_id = 5284
+AND S.fetched_at >= strftime('%s', 'now', '-7 days')
+ORDER BY A.buyout ASC;
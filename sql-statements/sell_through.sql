@@ -0,0 +1,17 @@
+-- SQLite
+-- Sell-through rate and sales velocity of every item over the last 14 days, see AuctionLifecycles
+-- Cancelled auctions are left out of the rate, they are mostly listed again at a lower price.
+-- Outcomes are guesses, an auction that sold shortly before it would have expired counts as expired.
+SELECT L.connected_realm_id, L.item_id, I.name,
+    SUM(L.outcome = 'sold') AS sold,
+    SUM(L.outcome = 'expired') AS expired,
+    SUM(L.outcome = 'cancelled') AS cancelled,
+    ROUND(1.0 * SUM(L.outcome = 'sold') / NULLIF(SUM(L.outcome IN ('sold', 'expired')), 0), 2) AS sell_through,
+    ROUND(SUM(CASE WHEN L.outcome = 'sold' THEN L.quantity ELSE 0 END) / 14.0, 1) AS sold_per_day,
+    ROUND(AVG(CASE WHEN L.outcome = 'sold' THEN (L.ended_at - L.first_seen) / 3600.0 END), 1) AS hours_to_sell,
+    MIN(CASE WHEN L.outcome = 'sold' THEN L.unit_price END) AS min_sold_unit_price
+FROM AuctionLifecycles L
+LEFT JOIN Items I ON I.item_id = L.item_id AND I.game_version = L.game_version
+WHERE L.ended_at >= strftime('%s', 'now', '-14 days')
+GROUP BY L.connected_realm_id, L.item_id
+ORDER BY sold_per_day DESC;
@@ -0,0 +1,13 @@
+-- SQLite
+-- Auction houses that went more than two hours without a complete snapshot
+SELECT connected_realm_id, auction_house_id,
+    datetime(previous_fetched_at, 'unixepoch') AS from_time,
+    datetime(fetched_at, 'unixepoch') AS to_time,
+    (fetched_at - previous_fetched_at) / 3600.0 AS hours
+FROM (
+    SELECT connected_realm_id, auction_house_id, fetched_at,
+        LAG(fetched_at) OVER (PARTITION BY connected_realm_id, auction_house_id ORDER BY fetched_at) AS previous_fetched_at
+    FROM Snapshots
+    WHERE status = 'complete')
+WHERE fetched_at - previous_fetched_at > 2 * 3600
+ORDER BY connected_realm_id, auction_house_id, fetched_at;
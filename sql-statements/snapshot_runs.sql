@@ -0,0 +1,14 @@
+-- SQLite
+-- Every import run and how many of its auction house dumps made it
+-- A run with failed or cut short snapshots is partial, the auctions of those houses are missing from it
+SELECT datetime(fetched_at, 'unixepoch') AS run,
+    COUNT(*) AS houses,
+    SUM(status = 'complete') AS complete,
+    SUM(status = 'failed') AS failed,
+    SUM(status = 'running') AS cut_short,
+    SUM(auction_count) AS auctions,
+    SUM(byte_size) AS bytes,
+    MAX(duration_ms) AS slowest_ms
+FROM Snapshots
+GROUP BY fetched_at
+ORDER BY fetched_at;
@@ -0,0 +1,18 @@
+-- SQLite
+-- Cheapest unit price of every random suffix of an item in each complete snapshot
+-- A row of Auctions was listed in every snapshot of its house from its first, snapshot_id, to its last, last_snapshot_id.
+-- Bid-only auctions have no unit price, they are counted on their own.
+SELECT datetime(S.fetched_at, 'unixepoch') AS time,
+    A.item_rand,
+    MIN(A.unit_price) AS min_unit_price,
+    SUM(A.buyout IS NOT NULL) AS buyouts,
+    SUM(A.buyout IS NULL) AS bid_only
+FROM Auctions A
+JOIN Snapshots F ON F.snapshot_id = A.snapshot_id
+JOIN Snapshots L ON L.snapshot_id = A.last_snapshot_id
+JOIN Snapshots S ON S.connected_realm_id = A.connected_realm_id AND S.auction_house_id = A.auction_house_id
+    AND S.fetched_at BETWEEN F.fetched_at AND L.fetched_at
+WHERE A.item_id = 10118
+AND S.status = 'complete'
+GROUP BY S.snapshot_id, A.item_rand
+ORDER BY S.fetched_at, A.item_rand;
@@ -4,24 +4,49 @@ import sqlite3
 import pandas as pd
 
 def format_currency(value):
+    if pd.isna(value):
+        return "no data"
+    value = int(value)
     gold = value // 10000
     silver = (value % 10000) // 100
     copper = value % 100
     return f"{gold}g {silver}s {copper}c"
 
-def craft_query(item_id, faction_id, realm_id):
-    return f"""SELECT 
-    strftime('%Y-%m-%d %H:00:00', timestamp, 'unixepoch') AS date_hour, 
-    MIN(buyout) AS min_buyout,
-    SUM(quantity) AS total_quantity
-    FROM Auctions
-    WHERE item_id = {item_id}
-    AND faction_id = {faction_id}
-    AND connected_realm_id = {realm_id}
-    AND timestamp >= strftime('%s', 'now', '-7 days')
-    AND buyout > 0
-    GROUP BY strftime('%Y-%m-%d %H:00:00', timestamp, 'unixepoch')
-    ORDER BY strftime('%Y-%m-%d %H:00:00', timestamp, 'unixepoch');
+# One row per snapshot of the auction house, so every run shows up on the time axis.
+# Failed snapshots and snapshots that were cut short have no price and no volume, they are gaps in the plot.
+# A complete snapshot without a listing of the item has a volume of 0.
+# A row of Auctions was listed in every snapshot from its first, snapshot_id, to its last, last_snapshot_id.
+# Prices are per unit, bid-only auctions have no price and are counted separately.
+# item_rand selects one random suffix of the item, e.g. "of the Monkey", None selects the item without a suffix.
+def craft_query(item_id, faction_id, realm_id, item_rand=None):
+    rand = "NULL" if item_rand is None else int(item_rand)
+    return f"""WITH Listings AS (
+        SELECT A.connected_realm_id, A.auction_house_id, A.quantity, A.buyout, A.unit_price,
+        F.fetched_at AS first_seen,
+        L.fetched_at AS last_seen
+        FROM Auctions A
+        JOIN Snapshots F ON F.snapshot_id = A.snapshot_id
+        JOIN Snapshots L ON L.snapshot_id = A.last_snapshot_id
+        WHERE A.item_id = {item_id}
+        AND A.item_rand IS {rand}
+    )
+    SELECT 
+    datetime(S.fetched_at, 'unixepoch') AS date_hour, 
+    S.status,
+    MIN(A.unit_price) AS min_buyout,
+    CASE WHEN S.status = 'complete' THEN COALESCE(SUM(CASE WHEN A.buyout IS NOT NULL THEN A.quantity END), 0) END AS total_quantity,
+    CASE WHEN S.status = 'complete' THEN COALESCE(SUM(CASE WHEN A.buyout IS NULL THEN A.quantity END), 0) END AS bid_only_quantity
+    FROM Snapshots S
+    LEFT JOIN Listings A
+    ON A.connected_realm_id = S.connected_realm_id
+    AND A.auction_house_id = S.auction_house_id
+    AND S.status = 'complete'
+    AND S.fetched_at BETWEEN A.first_seen AND A.last_seen
+    WHERE S.faction_id = {faction_id}
+    AND S.connected_realm_id = {realm_id}
+    AND S.fetched_at >= strftime('%s', 'now', '-7 days')
+    GROUP BY S.snapshot_id
+    ORDER BY S.fetched_at;
     """
 
 def panda_query(query):
@@ -38,7 +63,9 @@ def panda_query(query):
     # Create the hover text using the formatted buyout data and volume
     df['hover_text'] = df['day_of_week'] + ", " + df['date_hour'].dt.strftime('%Y-%m-%d %H:%M') + \
                     "<br>Price: " + df['formatted_min_buyout'] + \
-                    "<br>Volume: " + df['total_quantity'].astype(str)
+                    "<br>Volume: " + df['total_quantity'].astype('Int64').astype(str) + \
+                    "<br>Bid only: " + df['bid_only_quantity'].astype('Int64').astype(str) + \
+                    "<br>Snapshot: " + df['status']
 
     return df
 
@@ -46,7 +73,7 @@ def plot_data(df, item_name):
     # Create a Plotly figure
     fig = px.line(df, x='date_hour', y='min_buyout', 
                 title=f'Min Buyout for {item_name}',
-                labels={'min_buyout': 'Minimum Buyout Price', 'date_hour': 'Date'},
+                labels={'min_buyout': 'Minimum Buyout Price per Unit', 'date_hour': 'Date'},
                 custom_data=['hover_text'],
                 name="Price history")
 
@@ -71,7 +98,7 @@ def plot_data(df, item_name):
     fig.update_xaxes(tickformat='%a %Y-%m-%d %H:%M')
 
     # Set custom y-axis labels
-    y_values = list(range(df['min_buyout'].min(), df['min_buyout'].max() + 10000, 10000))
+    y_values = list(range(int(df['min_buyout'].min()), int(df['min_buyout'].max()) + 10000, 10000))
     y_labels = [format_currency(val) for val in y_values]
     fig.update_yaxes(tickvals=y_values, ticktext=y_labels, secondary_y=False)
 